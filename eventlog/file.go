@@ -0,0 +1,160 @@
+package eventlog
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+
+	"github.com/davfer/crudo/internal/frame"
+)
+
+var ErrCorruptedLog = errors.New("corrupted event log")
+
+// FileStorage appends the entries to a single file, each one framed by its length and checksums and synced before
+// Append returns. A torn entry at the tail of the file is dropped when it is opened.
+type FileStorage struct {
+	Path      string
+	file      *os.File
+	lock      *sync.Mutex
+	positions []int64 // positions of the entries in the file, by offset
+	size      int64
+}
+
+// OpenFileStorage opens the file at path, creating it when missing.
+func OpenFileStorage(path string) (*FileStorage, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("could not open event log: %w", err)
+	}
+
+	s := &FileStorage{Path: path, file: f, lock: &sync.Mutex{}}
+	if err = s.index(); errors.Is(err, io.ErrUnexpectedEOF) {
+		err = f.Truncate(s.size)
+	}
+	if err != nil {
+		_ = f.Close()
+		return nil, fmt.Errorf("could not index event log: %w", err)
+	}
+
+	return s, nil
+}
+
+func (s *FileStorage) Append(_ context.Context, entry Entry) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.file == nil {
+		return os.ErrClosed
+	}
+	if entry.Offset != uint64(len(s.positions)) {
+		return fmt.Errorf("could not append entry %d, next offset is %d", entry.Offset, len(s.positions))
+	}
+
+	body, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("could not encode entry: %w", err)
+	}
+	f, err := frame.Encode(body)
+	if err != nil {
+		return fmt.Errorf("could not encode entry: %w", err)
+	}
+
+	if _, err = s.file.WriteAt(f, s.size); err != nil {
+		return fmt.Errorf("could not write event log: %w", err)
+	}
+	if err = s.file.Sync(); err != nil {
+		return fmt.Errorf("could not sync event log: %w", err)
+	}
+	s.positions = append(s.positions, s.size)
+	s.size += int64(len(f))
+
+	return nil
+}
+
+func (s *FileStorage) Read(_ context.Context, from uint64, limit int) ([]Entry, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.file == nil {
+		return nil, os.ErrClosed
+	}
+	if from >= uint64(len(s.positions)) {
+		return []Entry{}, nil
+	}
+
+	r := bufio.NewReader(io.NewSectionReader(s.file, s.positions[from], s.size-s.positions[from]))
+	var entries []Entry
+	for len(entries) < limit || limit <= 0 {
+		entry, _, err := readEntry(r)
+		if errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+
+	return entries, nil
+}
+
+func (s *FileStorage) Next(_ context.Context) (uint64, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	return uint64(len(s.positions)), nil
+}
+
+func (s *FileStorage) Close() error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.file == nil {
+		return nil
+	}
+	err := s.file.Close()
+	s.file = nil
+
+	return err
+}
+
+// index records the position of every entry, leaving size after the last valid one.
+func (s *FileStorage) index() error {
+	r := bufio.NewReader(s.file)
+	for {
+		entry, n, err := readEntry(r)
+		if errors.Is(err, io.EOF) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if entry.Offset != uint64(len(s.positions)) {
+			return fmt.Errorf("%w: entry %d found at offset %d", ErrCorruptedLog, entry.Offset, len(s.positions))
+		}
+
+		s.positions = append(s.positions, s.size)
+		s.size += n
+	}
+}
+
+// readEntry reads the next framed entry and its size, io.EOF when there are no more and io.ErrUnexpectedEOF when the
+// last one is incomplete.
+func readEntry(r io.Reader) (Entry, int64, error) {
+	body, err := frame.Read(r)
+	if errors.Is(err, frame.ErrCorrupted) {
+		return Entry{}, 0, fmt.Errorf("%w: %v", ErrCorruptedLog, err)
+	} else if err != nil {
+		return Entry{}, 0, err
+	}
+
+	var entry Entry
+	if err = json.Unmarshal(body, &entry); err != nil {
+		return Entry{}, 0, fmt.Errorf("%w: could not decode entry: %v", ErrCorruptedLog, err)
+	}
+
+	return entry, int64(frame.HeaderSize + len(body)), nil
+}
@@ -0,0 +1,186 @@
+package eventlog
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/davfer/archit/patterns/opts"
+	"github.com/go-logr/logr"
+
+	"github.com/davfer/crudo/notifier"
+)
+
+var ErrLogNotStarted = errors.New("event log not started")
+
+// Entry is an appended event, offsets start at 0 and grow by one with every entry.
+type Entry struct {
+	Offset    uint64          `json:"offset"`
+	Topic     string          `json:"topic"`
+	Payload   json.RawMessage `json:"payload"`
+	Timestamp time.Time       `json:"timestamp"`
+}
+
+// Storage keeps the entries of a log durably.
+type Storage interface {
+	Append(ctx context.Context, entry Entry) error
+	// Read returns up to limit entries from the given offset on, in offset order
+	Read(ctx context.Context, from uint64, limit int) ([]Entry, error)
+	// Next returns the offset of the next entry to append
+	Next(ctx context.Context) (uint64, error)
+}
+
+// Log appends every event it is notified of to its storage and delivers them to its live subscribers, so history can
+// be replayed to rebuild read models. Events are encoded as JSON.
+type Log[K any] struct {
+	BatchSize int // BatchSize of the entries read at once while replaying
+	storage   Storage
+	live      *notifier.Notifier[K]
+	logger    logr.Logger
+	lock      *sync.Mutex
+	next      uint64
+	started   bool
+}
+
+func NewLog[K any](storage Storage, o ...opts.Opt[Log[K]]) *Log[K] {
+	l := opts.New[Log[K]](o...)
+
+	l.storage = storage
+	if l.BatchSize <= 0 {
+		l.BatchSize = 100
+	}
+	if l.logger.GetSink() == nil {
+		l.logger = logr.Discard()
+	}
+	l.live = notifier.NewNotifier[K]()
+	l.lock = &sync.Mutex{}
+
+	return &l
+}
+
+func WithBatchSize[K any](size int) opts.Opt[Log[K]] {
+	return func(l Log[K]) Log[K] {
+		l.BatchSize = size
+		return l
+	}
+}
+
+func WithLogger[K any](logger logr.Logger) opts.Opt[Log[K]] {
+	return func(l Log[K]) Log[K] {
+		l.logger = logger
+		return l
+	}
+}
+
+// Start reads the offset the log continues from.
+func (l *Log[K]) Start(ctx context.Context) error {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	next, err := l.storage.Next(ctx)
+	if err != nil {
+		return fmt.Errorf("could not read next offset: %w", err)
+	}
+	l.next = next
+	l.started = true
+	l.logger.Info("event log started", "offset", next)
+
+	return nil
+}
+
+// Notify appends the event and delivers it to the live subscribers, making the log a notifier.Publisher. Subscribers
+// must not notify the log they are subscribed to.
+func (l *Log[K]) Notify(ctx context.Context, topic string, event K) error {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("could not encode event: %w", err)
+	}
+
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	if !l.started {
+		return ErrLogNotStarted
+	}
+
+	offset := l.next
+	if err = l.storage.Append(ctx, Entry{Offset: offset, Topic: topic, Payload: payload, Timestamp: time.Now()}); err != nil {
+		l.logger.Error(err, "error appending event", "topic", topic)
+		return fmt.Errorf("could not append event: %w", err)
+	}
+	l.next++
+
+	return l.live.Notify(withOffset(notifier.WithTopic(ctx, topic), offset), event)
+}
+
+// Observe appends the event under the topic being handled, e.g. attached to every topic of a store.
+func (l *Log[K]) Observe(ctx context.Context, event K) error {
+	topic, _ := notifier.TopicFromContext(ctx)
+	return l.Notify(ctx, topic, event)
+}
+
+// Replay delivers the entries from the given offset on to the observer, with their topic and offset in the context,
+// and returns the offset to continue from. It stops at the first observer error returning the offset of the failing
+// entry.
+func (l *Log[K]) Replay(ctx context.Context, from uint64, observer notifier.ObserverCallback[K]) (uint64, error) {
+	for {
+		entries, err := l.storage.Read(ctx, from, l.BatchSize)
+		if err != nil {
+			return from, fmt.Errorf("could not read entries from offset %d: %w", from, err)
+		}
+		if len(entries) == 0 {
+			return from, nil
+		}
+
+		for _, entry := range entries {
+			var event K
+			if err = json.Unmarshal(entry.Payload, &event); err != nil {
+				return entry.Offset, fmt.Errorf("could not decode entry %d: %w", entry.Offset, err)
+			}
+			if err = observer(withOffset(notifier.WithTopic(ctx, entry.Topic), entry.Offset), event); err != nil {
+				return entry.Offset, fmt.Errorf("could not replay entry %d: %w", entry.Offset, err)
+			}
+			from = entry.Offset + 1
+		}
+	}
+}
+
+// Subscribe replays the entries from the given offset on to the observer and then delivers it the live ones, with no
+// gap nor duplicate between both.
+func (l *Log[K]) Subscribe(ctx context.Context, from uint64, observer notifier.ObserverCallback[K]) (*notifier.Subscription, error) {
+	// most of the history is replayed while appends go on
+	from, err := l.Replay(ctx, from, observer)
+	if err != nil {
+		return nil, err
+	}
+
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	if _, err = l.Replay(ctx, from, observer); err != nil {
+		return nil, err
+	}
+
+	return l.live.Attach(observerFunc[K](observer))
+}
+
+type observerFunc[K any] notifier.ObserverCallback[K]
+
+func (f observerFunc[K]) Handle(ctx context.Context, event K) error {
+	return f(ctx, event)
+}
+
+type offsetKey struct{}
+
+func withOffset(ctx context.Context, offset uint64) context.Context {
+	return context.WithValue(ctx, offsetKey{}, offset)
+}
+
+// OffsetFromContext returns the offset of the entry being handled.
+func OffsetFromContext(ctx context.Context) (uint64, bool) {
+	offset, ok := ctx.Value(offsetKey{}).(uint64)
+	return offset, ok
+}
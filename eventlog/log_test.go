@@ -0,0 +1,203 @@
+package eventlog_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/davfer/crudo"
+	"github.com/davfer/crudo/eventlog"
+	"github.com/davfer/crudo/inmemory"
+	"github.com/davfer/crudo/notifier"
+	"github.com/davfer/go-specification"
+)
+
+type testEvent struct {
+	Name string
+}
+
+func TestLog(t *testing.T) {
+	tests := []struct {
+		name    string
+		storage func(t *testing.T) eventlog.Storage
+	}{
+		{"file", func(t *testing.T) eventlog.Storage {
+			s, err := eventlog.OpenFileStorage(filepath.Join(t.TempDir(), "events.log"))
+			if err != nil {
+				t.Fatalf("OpenFileStorage() error = %v", err)
+			}
+			t.Cleanup(func() { _ = s.Close() })
+			return s
+		}},
+		{"repository", func(t *testing.T) eventlog.Storage {
+			return eventlog.NewRepositoryStorage(inmemory.NewRepository([]*eventlog.Record{}))
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.TODO()
+			l := eventlog.NewLog[testEvent](tt.storage(t), eventlog.WithBatchSize[testEvent](2))
+			if err := l.Notify(ctx, "added", testEvent{Name: "a"}); err == nil {
+				t.Errorf("Notify() before Start expected error")
+			}
+			if err := l.Start(ctx); err != nil {
+				t.Fatalf("Start() error = %v", err)
+			}
+
+			n := notifier.NewTopicCallbackNotifier[testEvent]([]string{"added", "updated"})
+			_, _ = n.Attach("*", l.Observe)
+			for _, e := range []struct{ topic, name string }{{"added", "a"}, {"updated", "a"}, {"added", "b"}} {
+				if err := n.Notify(ctx, e.topic, testEvent{Name: e.name}); err != nil {
+					t.Fatalf("Notify() error = %v", err)
+				}
+			}
+
+			var got []string
+			record := func(ctx context.Context, e testEvent) error {
+				topic, _ := notifier.TopicFromContext(ctx)
+				offset, _ := eventlog.OffsetFromContext(ctx)
+				got = append(got, fmt.Sprintf("%d:%s:%s", offset, topic, e.Name))
+				return nil
+			}
+
+			next, err := l.Replay(ctx, 1, record)
+			if err != nil || next != 3 {
+				t.Fatalf("Replay() next = %d, error = %v, want 3", next, err)
+			}
+			if want := []string{"1:updated:a", "2:added:b"}; !reflect.DeepEqual(got, want) {
+				t.Errorf("Replay() got = %v, want %v", got, want)
+			}
+
+			// a new subscriber catches up before receiving the live events
+			got = nil
+			sub, err := l.Subscribe(ctx, 0, record)
+			if err != nil {
+				t.Fatalf("Subscribe() error = %v", err)
+			}
+			_ = l.Notify(ctx, "updated", testEvent{Name: "b"})
+			sub.Unsubscribe()
+			_ = l.Notify(ctx, "updated", testEvent{Name: "c"})
+
+			want := []string{"0:added:a", "1:updated:a", "2:added:b", "3:updated:b"}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("Subscribe() got = %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestFileStorage_Reopen(t *testing.T) {
+	ctx := context.TODO()
+	path := filepath.Join(t.TempDir(), "events.log")
+	s, err := eventlog.OpenFileStorage(path)
+	if err != nil {
+		t.Fatalf("OpenFileStorage() error = %v", err)
+	}
+	l := eventlog.NewLog[testEvent](s)
+	_ = l.Start(ctx)
+	_ = l.Notify(ctx, "added", testEvent{Name: "a"})
+	_ = l.Notify(ctx, "added", testEvent{Name: "b"})
+	_ = s.Close()
+
+	// torn write of a third entry
+	f, _ := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0o644)
+	_, _ = f.Write([]byte{42, 0, 0, 0, 1, 2})
+	_ = f.Close()
+
+	if s, err = eventlog.OpenFileStorage(path); err != nil {
+		t.Fatalf("OpenFileStorage() error = %v", err)
+	}
+	defer s.Close()
+	l = eventlog.NewLog[testEvent](s)
+	if err = l.Start(ctx); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	_ = l.Notify(ctx, "added", testEvent{Name: "c"})
+
+	var names []string
+	next, err := l.Replay(ctx, 0, func(ctx context.Context, e testEvent) error {
+		names = append(names, e.Name)
+		return nil
+	})
+	if err != nil || next != 3 {
+		t.Fatalf("Replay() next = %d, error = %v, want 3", next, err)
+	}
+	if !reflect.DeepEqual(names, []string{"a", "b", "c"}) {
+		t.Errorf("Replay() got = %v, want [a b c]", names)
+	}
+}
+
+// countingRepository counts the records the log reads.
+type countingRepository struct {
+	crudo.Repository[*eventlog.Record]
+	read int
+}
+
+func (r *countingRepository) ReadAll(ctx context.Context) ([]*eventlog.Record, error) {
+	records, err := r.Repository.ReadAll(ctx)
+	r.read += len(records)
+	return records, err
+}
+
+func (r *countingRepository) Match(ctx context.Context, c specification.Criteria) ([]*eventlog.Record, error) {
+	records, err := r.Repository.Match(ctx, c)
+	r.read += len(records)
+	return records, err
+}
+
+func TestRepositoryStorage_Bounded(t *testing.T) {
+	ctx := context.TODO()
+	repo := &countingRepository{Repository: inmemory.NewRepository([]*eventlog.Record{})}
+	for i := 0; i < 100; i++ {
+		_, _ = repo.Repository.Create(ctx, &eventlog.Record{Offset: i, Topic: "added"})
+	}
+	s := eventlog.NewRepositoryStorage(repo)
+
+	if next, err := s.Next(ctx); err != nil || next != 100 {
+		t.Fatalf("Next() = %d, error = %v, want 100", next, err)
+	}
+	if repo.read > 20 {
+		t.Errorf("Next() read %d records, want a search", repo.read)
+	}
+
+	repo.read = 0
+	entries, err := s.Read(ctx, 40, 5)
+	if err != nil || len(entries) != 5 || entries[0].Offset != 40 || entries[4].Offset != 44 {
+		t.Fatalf("Read() = %v, error = %v, want offsets 40 to 44", entries, err)
+	}
+	if repo.read != 5 {
+		t.Errorf("Read() read %d records, want 5", repo.read)
+	}
+
+	_ = s.Append(ctx, eventlog.Entry{Offset: 100, Topic: "added"})
+	if next, _ := s.Next(ctx); next != 101 {
+		t.Errorf("Next() after Append = %d, want 101", next)
+	}
+}
+
+func TestFileStorage_Corrupted(t *testing.T) {
+	ctx := context.TODO()
+	path := filepath.Join(t.TempDir(), "events.log")
+	s, err := eventlog.OpenFileStorage(path)
+	if err != nil {
+		t.Fatalf("OpenFileStorage() error = %v", err)
+	}
+	l := eventlog.NewLog[testEvent](s)
+	_ = l.Start(ctx)
+	_ = l.Notify(ctx, "added", testEvent{Name: "a"})
+	_ = l.Notify(ctx, "added", testEvent{Name: "b"})
+	_ = s.Close()
+
+	// a corrupted length must not pass for a torn tail
+	data, _ := os.ReadFile(path)
+	data[2] ^= 0x01
+	_ = os.WriteFile(path, data, 0o644)
+
+	if _, err = eventlog.OpenFileStorage(path); !errors.Is(err, eventlog.ErrCorruptedLog) {
+		t.Errorf("OpenFileStorage() error = %v, want %v", err, eventlog.ErrCorruptedLog)
+	}
+}
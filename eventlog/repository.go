@@ -0,0 +1,154 @@
+package eventlog
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/davfer/go-specification"
+	"golang.org/x/exp/slices"
+
+	"github.com/davfer/crudo"
+	"github.com/davfer/crudo/entity"
+)
+
+// Record is an entry stored by a RepositoryStorage.
+type Record struct {
+	Id        string    `json:"id" bson:"_id,omitempty"`
+	Offset    int       `json:"offset" bson:"offset"`
+	Topic     string    `json:"topic" bson:"topic"`
+	Payload   []byte    `json:"payload" bson:"payload"`
+	Timestamp time.Time `json:"timestamp" bson:"timestamp"`
+}
+
+func (r *Record) GetID() entity.ID {
+	return entity.ID(r.Id)
+}
+
+func (r *Record) SetID(id entity.ID) error {
+	r.Id = id.String()
+	return nil
+}
+
+func (r *Record) GetResourceID() (string, error) {
+	return strconv.Itoa(r.Offset), nil
+}
+
+func (r *Record) SetResourceID(s string) error {
+	offset, err := strconv.Atoi(s)
+	if err != nil {
+		return fmt.Errorf("could not parse offset %s: %w", s, err)
+	}
+	r.Offset = offset
+
+	return nil
+}
+
+// RepositoryStorage keeps the entries as records of any repository, e.g. a mongo collection indexed by offset. Reads
+// match the range of offsets they return and the next offset is found with a binary search, so neither scans the whole
+// log.
+type RepositoryStorage struct {
+	repo crudo.Repository[*Record]
+	lock *sync.Mutex
+	next *uint64 // next offset once known
+}
+
+func NewRepositoryStorage(repo crudo.Repository[*Record]) *RepositoryStorage {
+	return &RepositoryStorage{repo: repo, lock: &sync.Mutex{}}
+}
+
+func (s *RepositoryStorage) Append(ctx context.Context, entry Entry) error {
+	_, err := s.repo.Create(ctx, &Record{
+		Offset:    int(entry.Offset),
+		Topic:     entry.Topic,
+		Payload:   entry.Payload,
+		Timestamp: entry.Timestamp,
+	})
+	if err != nil {
+		return fmt.Errorf("could not create record %d: %w", entry.Offset, err)
+	}
+
+	s.lock.Lock()
+	if s.next != nil && entry.Offset >= *s.next {
+		*s.next = entry.Offset + 1
+	}
+	s.lock.Unlock()
+
+	return nil
+}
+
+func (s *RepositoryStorage) Read(ctx context.Context, from uint64, limit int) ([]Entry, error) {
+	var c specification.Criteria = specification.Attr{Name: "Offset", Value: int(from), Comparison: specification.ComparisonGte}
+	if limit > 0 {
+		// offsets have no gaps, so the range holds limit records at most
+		c = specification.And{Operands: []specification.Criteria{
+			c,
+			specification.Attr{Name: "Offset", Value: int(from) + limit, Comparison: specification.ComparisonLt},
+		}}
+	}
+
+	records, err := s.repo.Match(ctx, c)
+	if err != nil {
+		return nil, fmt.Errorf("could not match records: %w", err)
+	}
+	slices.SortFunc(records, func(a, b *Record) int {
+		return a.Offset - b.Offset
+	})
+
+	entries := make([]Entry, len(records))
+	for i, r := range records {
+		entries[i] = Entry{Offset: uint64(r.Offset), Topic: r.Topic, Payload: r.Payload, Timestamp: r.Timestamp}
+	}
+
+	return entries, nil
+}
+
+func (s *RepositoryStorage) Next(ctx context.Context) (uint64, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.next != nil {
+		return *s.next, nil
+	}
+
+	// offsets have no gaps, so the next one follows the last offset found
+	found, err := s.exists(ctx, 0)
+	if err != nil || !found {
+		return 0, err
+	}
+	last, missing := uint64(0), uint64(1)
+	for {
+		if found, err = s.exists(ctx, missing); err != nil {
+			return 0, err
+		} else if !found {
+			break
+		}
+		last, missing = missing, missing*2
+	}
+	for missing-last > 1 {
+		mid := last + (missing-last)/2
+		if found, err = s.exists(ctx, mid); err != nil {
+			return 0, err
+		} else if found {
+			last = mid
+		} else {
+			missing = mid
+		}
+	}
+
+	next := last + 1
+	s.next = &next
+
+	return next, nil
+}
+
+func (s *RepositoryStorage) exists(ctx context.Context, offset uint64) (bool, error) {
+	records, err := s.repo.Match(ctx, specification.Attr{Name: "Offset", Value: int(offset), Comparison: specification.ComparisonEq})
+	if err != nil {
+		return false, fmt.Errorf("could not match record %d: %w", offset, err)
+	}
+
+	return len(records) > 0, nil
+}
@@ -0,0 +1,47 @@
+package inmemory
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/davfer/crudo/entity"
+)
+
+// Cloneable entities provide their own deep copy for isolated repositories, otherwise the codec is used.
+type Cloneable[K entity.Entity] interface {
+	Clone() K
+}
+
+type Codec[K entity.Entity] interface {
+	Marshal(K) ([]byte, error)
+	Unmarshal([]byte) (K, error)
+}
+
+type JSONCodec[K entity.Entity] struct{}
+
+func (c JSONCodec[K]) Marshal(k K) ([]byte, error) {
+	return json.Marshal(k)
+}
+
+func (c JSONCodec[K]) Unmarshal(data []byte) (k K, err error) {
+	err = json.Unmarshal(data, &k)
+	return
+}
+
+// Clone deep-copies e, through Cloneable when implemented or through the codec otherwise.
+func Clone[K entity.Entity](e K, codec Codec[K]) (K, error) {
+	if c, ok := entity.Entity(e).(Cloneable[K]); ok {
+		return c.Clone(), nil
+	}
+
+	data, err := codec.Marshal(e)
+	if err != nil {
+		return e, fmt.Errorf("could not clone entity: %w", err)
+	}
+	clone, err := codec.Unmarshal(data)
+	if err != nil {
+		return e, fmt.Errorf("could not clone entity: %w", err)
+	}
+
+	return clone, nil
+}
@@ -3,7 +3,6 @@ package inmemory
 import (
 	"context"
 	"fmt"
-
 	"sync"
 
 	"github.com/davfer/archit/patterns/opts"
@@ -13,23 +12,71 @@ import (
 )
 
 type Repository[K entity.Entity] struct {
-	Collection []K
-	lock       *sync.Mutex
-	policy     Policy[K]
-	idStrategy IdStrategy[K]
+	Collection  []K
+	lock        *sync.RWMutex
+	policy      Policy[K]
+	idStrategy  IdStrategy[K]
+	codec       Codec[K]
+	persistence *Persistence[K]
+	isolated    bool
+	onEvict     []func(K)
 }
 
 func NewRepository[K entity.Entity](c []K, o ...opts.Opt[Repository[K]]) *Repository[K] {
 	r := opts.New[Repository[K]](o...)
 
 	r.Collection = c
-	r.lock = &sync.Mutex{}
+	r.lock = &sync.RWMutex{}
+	if r.codec == nil {
+		r.codec = JSONCodec[K]{}
+	}
 
 	return &r
 }
 
 func (r *Repository[K]) Start(ctx context.Context, onBootstrap func(ctx context.Context) error) error {
-	return onBootstrap(ctx)
+	if _, ok := r.policy.(AccessPolicy[K]); ok && r.persistence != nil {
+		// the log would replay a different order of the collection, evicting other entities
+		return fmt.Errorf("could not restore repository: access policies cannot be persisted")
+	}
+	if r.persistence == nil {
+		if onBootstrap == nil {
+			return nil
+		}
+		return onBootstrap(ctx)
+	}
+
+	r.lock.Lock()
+	seed := r.Collection
+	r.Collection = nil
+	restored, err := r.persistence.restore(r.codec, r.apply)
+	if err == nil && !restored && len(seed) > 0 {
+		// nothing persisted yet, the initial collection becomes the first snapshot
+		r.Collection = seed
+		err = r.persistence.compact(r.Collection)
+	}
+	r.lock.Unlock()
+	if err != nil {
+		return fmt.Errorf("could not restore repository: %w", err)
+	}
+
+	if !restored && onBootstrap != nil {
+		return onBootstrap(ctx)
+	}
+
+	return nil
+}
+
+// Close flushes and releases the persistence files, if any.
+func (r *Repository[K]) Close() error {
+	if r.persistence == nil {
+		return nil
+	}
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	return r.persistence.close()
 }
 
 func (r *Repository[K]) Create(ctx context.Context, e K) (K, error) {
@@ -61,28 +108,51 @@ func (r *Repository[K]) Create(ctx context.Context, e K) (K, error) {
 		}
 	}
 
-	if r.policy != nil {
-		c, err := r.policy.ApplyCreate(ctx, e, r.Collection)
-		if err != nil {
-			return e, err
-		}
+	stored, err := r.isolate(e)
+	if err != nil {
+		return e, err
+	}
+	c, err := r.insert(ctx, stored)
+	if err != nil {
+		return e, err
+	}
+	if err = r.persist(opCreate, stored); err != nil {
+		return e, err
+	}
 
-		r.Collection = c
-	} else { // Nil policy, just append
-		r.Collection = append(r.Collection, e)
+	evicted := r.evicted(stored, c)
+	r.Collection = c
+	for _, d := range evicted {
+		for _, f := range r.onEvict {
+			f(d)
+		}
 	}
 
-	return e, nil
+	return e, r.compact()
 }
 
 func (r *Repository[K]) Read(ctx context.Context, id entity.ID) (e K, err error) {
-	r.lock.Lock()
-	defer r.lock.Unlock()
+	if p, ok := r.policy.(AccessPolicy[K]); ok {
+		// the policy reorders the collection on reads
+		r.lock.Lock()
+		defer r.lock.Unlock()
+
+		if i := r.indexOf(id); i >= 0 {
+			e = r.Collection[i]
+			r.Collection = p.ApplyRead(ctx, e, r.Collection)
+			return r.isolate(e)
+		}
+
+		err = entity.ErrEntityNotFound
+		return
+	}
+
+	r.lock.RLock()
+	defer r.lock.RUnlock()
 
 	for _, i := range r.Collection {
 		if i.GetID() == id {
-			e = i
-			return
+			return r.isolate(i)
 		}
 	}
 
@@ -91,21 +161,32 @@ func (r *Repository[K]) Read(ctx context.Context, id entity.ID) (e K, err error)
 }
 
 func (r *Repository[K]) Match(ctx context.Context, c specification.Criteria) ([]K, error) {
-	r.lock.Lock()
-	defer r.lock.Unlock()
+	r.lock.RLock()
+	defer r.lock.RUnlock()
 
 	var result []K
 	for _, e := range r.Collection {
 		if c.IsSatisfiedBy(e) {
+			e, err := r.isolate(e)
+			if err != nil {
+				return nil, err
+			}
 			result = append(result, e)
 		}
 	}
 
+	if q, ok := c.(Query[K]); ok {
+		return q.apply(result)
+	}
+
 	return result, nil
 }
 
 func (r *Repository[K]) MatchOne(ctx context.Context, c specification.Criteria) (k K, err error) {
-	ks, _ := r.Match(ctx, c)
+	ks, err := r.Match(ctx, c)
+	if err != nil {
+		return k, err
+	}
 	if len(ks) == 0 {
 		return k, entity.ErrEntityNotFound
 	}
@@ -114,33 +195,160 @@ func (r *Repository[K]) MatchOne(ctx context.Context, c specification.Criteria)
 	return
 }
 
+// ReadAll returns a snapshot of the collection, later writes do not change the returned slice.
 func (r *Repository[K]) ReadAll(ctx context.Context) ([]K, error) {
-	return r.Collection, nil
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	result := make([]K, len(r.Collection))
+	for i, e := range r.Collection {
+		e, err := r.isolate(e)
+		if err != nil {
+			return nil, err
+		}
+		result[i] = e
+	}
+
+	return result, nil
 }
 
 func (r *Repository[K]) Update(ctx context.Context, entity K) error {
 	r.lock.Lock()
 	defer r.lock.Unlock()
 
-	for i, e := range r.Collection {
-		if e.GetID() == entity.GetID() {
-			r.Collection[i] = entity
-			break
-		}
+	i := r.indexOf(entity.GetID())
+	if i < 0 {
+		return nil
+	}
+	stored, err := r.isolate(entity)
+	if err != nil {
+		return err
+	}
+	if err = r.persist(opUpdate, stored); err != nil {
+		return err
 	}
 
-	return nil
+	r.Collection[i] = stored
+	return r.compact()
 }
 
 func (r *Repository[K]) Delete(ctx context.Context, entity K) error {
 	r.lock.Lock()
 	defer r.lock.Unlock()
 
+	i := r.indexOf(entity.GetID())
+	if i < 0 {
+		return nil
+	}
+	if err := r.persist(opDelete, entity); err != nil {
+		return err
+	}
+
+	r.Collection = append(r.Collection[:i], r.Collection[i+1:]...)
+	return r.compact()
+}
+
+// isolate returns a deep copy of e when the repository is isolated, so callers never share the stored entities.
+func (r *Repository[K]) isolate(e K) (K, error) {
+	if !r.isolated {
+		return e, nil
+	}
+
+	return Clone(e, r.codec)
+}
+
+// insert returns the collection with e added, as decided by the policy.
+func (r *Repository[K]) insert(ctx context.Context, e K) ([]K, error) {
+	if r.policy != nil {
+		return r.policy.ApplyCreate(ctx, e, r.Collection)
+	}
+
+	// Nil policy, just append
+	return append(r.Collection, e), nil
+}
+
+// evicted returns the entities of the collection, along with the created e, that the policy left out of c.
+func (r *Repository[K]) evicted(e K, c []K) []K {
+	if len(r.onEvict) == 0 || len(c) > len(r.Collection) {
+		return nil
+	}
+
+	kept := make(map[entity.ID]struct{}, len(c))
+	for _, d := range c {
+		kept[d.GetID()] = struct{}{}
+	}
+	var evicted []K
+	for _, d := range r.Collection {
+		if _, ok := kept[d.GetID()]; !ok {
+			evicted = append(evicted, d)
+		}
+	}
+	if _, ok := kept[e.GetID()]; !ok {
+		evicted = append(evicted, e)
+	}
+
+	return evicted
+}
+
+func (r *Repository[K]) indexOf(id entity.ID) int {
 	for i, e := range r.Collection {
-		if e.GetID() == entity.GetID() {
+		if e.GetID() == id {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// apply replays a persisted operation on the collection.
+func (r *Repository[K]) apply(op operation, e K) error {
+	switch op {
+	case opCreate:
+		// creates are replayed as upserts, a compaction interrupted before truncating the log leaves them in both
+		// the snapshot and the log
+		if i := r.indexOf(e.GetID()); i >= 0 {
+			r.Collection[i] = e
+			return nil
+		}
+		c, err := r.insert(context.Background(), e)
+		if err != nil {
+			return err
+		}
+		r.Collection = c
+	case opUpdate:
+		if i := r.indexOf(e.GetID()); i >= 0 {
+			r.Collection[i] = e
+		}
+	case opDelete:
+		if i := r.indexOf(e.GetID()); i >= 0 {
 			r.Collection = append(r.Collection[:i], r.Collection[i+1:]...)
-			break
 		}
+	default:
+		return fmt.Errorf("unknown operation %d", op)
+	}
+
+	return nil
+}
+
+func (r *Repository[K]) persist(op operation, e K) error {
+	if r.persistence == nil {
+		return nil
+	}
+
+	if err := r.persistence.append(op, e); err != nil {
+		return fmt.Errorf("could not persist entity: %w", err)
+	}
+
+	return nil
+}
+
+func (r *Repository[K]) compact() error {
+	if r.persistence == nil || !r.persistence.shouldCompact() {
+		return nil
+	}
+
+	if err := r.persistence.compact(r.Collection); err != nil {
+		return fmt.Errorf("could not compact repository: %w", err)
 	}
 
 	return nil
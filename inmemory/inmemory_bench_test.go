@@ -0,0 +1,103 @@
+package inmemory_test
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/davfer/crudo/entity"
+	"github.com/davfer/crudo/inmemory"
+	"github.com/davfer/go-specification"
+)
+
+func newBenchRepository(size int) *inmemory.Repository[*testMemoEntity] {
+	c := make([]*testMemoEntity, size)
+	for i := range c {
+		c[i] = &testMemoEntity{Id: strconv.Itoa(i), Attr1: "attr" + strconv.Itoa(i%10)}
+	}
+
+	return inmemory.NewRepository(c)
+}
+
+func TestRepository_Concurrency(t *testing.T) {
+	ctx := context.TODO()
+	r := newBenchRepository(100)
+
+	var wg sync.WaitGroup
+	for w := 0; w < 8; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < 200; i++ {
+				id := fmt.Sprintf("w%d-%d", w, i)
+				switch i % 5 {
+				case 0:
+					_, _ = r.Create(ctx, &testMemoEntity{Id: id})
+				case 1:
+					_ = r.Update(ctx, &testMemoEntity{Id: strconv.Itoa(i % 100), Attr1: "updated"})
+				case 2:
+					_ = r.Delete(ctx, &testMemoEntity{Id: fmt.Sprintf("w%d-%d", w, i-2)})
+				case 3:
+					_, _ = r.Match(ctx, specification.Attr{Name: "Attr1", Value: "updated", Comparison: specification.ComparisonEq})
+				default:
+					all, _ := r.ReadAll(ctx)
+					for _, e := range all {
+						_ = e.GetID()
+					}
+					_, _ = r.Read(ctx, entity.ID(strconv.Itoa(i%100)))
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+
+	// every created entity is deleted two iterations later
+	all, _ := r.ReadAll(ctx)
+	if len(all) != 100 {
+		t.Errorf("ReadAll() got = %d entities, want %d", len(all), 100)
+	}
+}
+
+func BenchmarkRepository_MixedReadWrite(b *testing.B) {
+	for _, readRatio := range []int{50, 90, 99} {
+		b.Run(fmt.Sprintf("reads=%d%%", readRatio), func(b *testing.B) {
+			ctx := context.TODO()
+			r := newBenchRepository(1000)
+			var seq atomic.Int64
+
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					n := seq.Add(1)
+					id := entity.ID(strconv.FormatInt(n%1000, 10))
+					if int(n%100) < readRatio {
+						_, _ = r.Read(ctx, id)
+					} else {
+						_ = r.Update(ctx, &testMemoEntity{Id: id.String(), Attr1: "updated"})
+					}
+				}
+			})
+		})
+	}
+}
+
+func BenchmarkRepository_ReadAllUnderWrites(b *testing.B) {
+	ctx := context.TODO()
+	r := newBenchRepository(1000)
+	var seq atomic.Int64
+
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			n := seq.Add(1)
+			if n%10 == 0 {
+				_ = r.Update(ctx, &testMemoEntity{Id: strconv.FormatInt(n%1000, 10), Attr1: "updated"})
+			} else {
+				_, _ = r.ReadAll(ctx)
+			}
+		}
+	})
+}
@@ -480,3 +480,65 @@ func TestRepository_Start(t *testing.T) {
 		})
 	}
 }
+
+type testCloneEntity struct {
+	testMemoEntity
+	clones *int
+}
+
+func (t *testCloneEntity) Clone() *testCloneEntity {
+	*t.clones++
+	c := *t
+	return &c
+}
+
+func TestRepository_Isolation(t *testing.T) {
+	t.Run("Test isolation with codec", func(t *testing.T) {
+		ctx := context.TODO()
+		r := inmemory.NewRepository(nil, inmemory.WithIsolation[*testMemoEntity]())
+
+		created, err := r.Create(ctx, &testMemoEntity{Id: "1", Attr1: "attr1"})
+		if err != nil {
+			t.Fatalf("Create() error = %v", err)
+		}
+		created.Attr1 = "changed after create"
+
+		read, _ := r.Read(ctx, "1")
+		if read.Attr1 != "attr1" {
+			t.Errorf("Read() got = %v, want %v", read.Attr1, "attr1")
+		}
+		read.Attr1 = "changed after read"
+
+		all, _ := r.ReadAll(ctx)
+		all[0].Attr1 = "changed after read all"
+		_, _ = r.Create(ctx, &testMemoEntity{Id: "2", Attr1: "attr2"})
+		if len(all) != 1 {
+			t.Errorf("ReadAll() snapshot len = %d, want 1", len(all))
+		}
+
+		matched, _ := r.Match(ctx, specification.Attr{Name: "Attr1", Value: "attr1", Comparison: specification.ComparisonEq})
+		if len(matched) != 1 {
+			t.Errorf("Match() got = %v, want 1 entity", matched)
+		}
+	})
+
+	t.Run("Test isolation with cloneable", func(t *testing.T) {
+		ctx := context.TODO()
+		clones := 0
+		r := inmemory.NewRepository(nil, inmemory.WithIsolation[*testCloneEntity]())
+
+		e := &testCloneEntity{testMemoEntity: testMemoEntity{Id: "1", Attr1: "attr1"}, clones: &clones}
+		if _, err := r.Create(ctx, e); err != nil {
+			t.Fatalf("Create() error = %v", err)
+		}
+		e.Attr1 = "changed"
+
+		read, _ := r.Read(ctx, "1")
+		if read.Attr1 != "attr1" || read == e {
+			t.Errorf("Read() got = %v, want an isolated copy", read)
+		}
+		if clones != 2 {
+			t.Errorf("Clone() calls = %d, want 2", clones)
+		}
+	})
+}
@@ -18,3 +18,35 @@ func WithIdStrategy[K entity.Entity](i IdStrategy[K]) opts.Opt[Repository[K]] {
 		return s
 	}
 }
+
+func WithCodec[K entity.Entity](c Codec[K]) opts.Opt[Repository[K]] {
+	return func(s Repository[K]) Repository[K] {
+		s.codec = c
+		return s
+	}
+}
+
+// WithPersistence logs the writes of the repository, it cannot be used along with an AccessPolicy.
+func WithPersistence[K entity.Entity](p *Persistence[K]) opts.Opt[Repository[K]] {
+	return func(s Repository[K]) Repository[K] {
+		s.persistence = p
+		return s
+	}
+}
+
+// WithIsolation deep-clones entities on every write and read so callers cannot mutate the stored collection.
+func WithIsolation[K entity.Entity]() opts.Opt[Repository[K]] {
+	return func(s Repository[K]) Repository[K] {
+		s.isolated = true
+		return s
+	}
+}
+
+// WithOnEvict calls evicted with every entity the policy drops from the collection or refuses to add, e.g. to release
+// what is kept about it elsewhere. It is called while the repository is locked, so it must not use the repository.
+func WithOnEvict[K entity.Entity](evicted func(e K)) opts.Opt[Repository[K]] {
+	return func(s Repository[K]) Repository[K] {
+		s.onEvict = append(s.onEvict, evicted)
+		return s
+	}
+}
@@ -0,0 +1,299 @@
+package inmemory
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/davfer/archit/patterns/opts"
+	"github.com/davfer/crudo/entity"
+	"github.com/davfer/crudo/internal/frame"
+)
+
+var ErrCorruptedLog = errors.New("corrupted persistence log")
+var ErrPersistenceNotStarted = errors.New("persistence not started")
+
+const (
+	snapshotFile = "snapshot.db"
+	walFile      = "wal.log"
+)
+
+type SyncPolicy string
+
+const (
+	SyncAlways   SyncPolicy = "always"   // SyncAlways fsyncs the log after every write
+	SyncInterval SyncPolicy = "interval" // SyncInterval fsyncs the log at most once per interval
+	SyncNever    SyncPolicy = "never"    // SyncNever leaves flushing to the operating system
+)
+
+type operation byte
+
+const (
+	opCreate operation = iota + 1
+	opUpdate
+	opDelete
+)
+
+// Persistence keeps an append-only write-ahead log of the repository writes and periodically compacts it into a
+// snapshot of the whole collection. Both files live in Dir and are replayed when the repository is started.
+type Persistence[K entity.Entity] struct {
+	Dir           string
+	SyncPolicy    SyncPolicy
+	SyncInterval  time.Duration
+	SnapshotEvery int // SnapshotEvery compacts the log after the given number of writes, 0 disables compaction
+	codec         Codec[K]
+	wal           *os.File
+	records       int
+	lastSync      time.Time
+}
+
+func NewPersistence[K entity.Entity](dir string, o ...opts.Opt[Persistence[K]]) *Persistence[K] {
+	p := opts.New[Persistence[K]](o...)
+
+	p.Dir = dir
+	if p.SyncPolicy == "" {
+		p.SyncPolicy = SyncAlways
+	}
+	if p.SyncInterval <= 0 {
+		p.SyncInterval = time.Second
+	}
+
+	return &p
+}
+
+func WithSyncPolicy[K entity.Entity](policy SyncPolicy, interval time.Duration) opts.Opt[Persistence[K]] {
+	return func(p Persistence[K]) Persistence[K] {
+		p.SyncPolicy = policy
+		p.SyncInterval = interval
+		return p
+	}
+}
+
+func WithSnapshotEvery[K entity.Entity](writes int) opts.Opt[Persistence[K]] {
+	return func(p Persistence[K]) Persistence[K] {
+		p.SnapshotEvery = writes
+		return p
+	}
+}
+
+// restore replays the snapshot and the log through apply and opens the log for appending. It reports whether any
+// persisted state was found.
+func (p *Persistence[K]) restore(codec Codec[K], apply func(operation, K) error) (bool, error) {
+	p.codec = codec
+	if err := os.MkdirAll(p.Dir, 0o755); err != nil {
+		return false, fmt.Errorf("could not create persistence dir: %w", err)
+	}
+
+	snapshotFound, err := p.replaySnapshot(apply)
+	if err != nil {
+		return false, err
+	}
+
+	wal, err := os.OpenFile(filepath.Join(p.Dir, walFile), os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return false, fmt.Errorf("could not open log: %w", err)
+	}
+
+	offset, records, err := p.replay(wal, apply)
+	if errors.Is(err, io.ErrUnexpectedEOF) {
+		// torn write at the tail of the log, drop the incomplete record
+		err = wal.Truncate(offset)
+	}
+	if err != nil {
+		_ = wal.Close()
+		return false, fmt.Errorf("could not replay log: %w", err)
+	}
+	if _, err = wal.Seek(offset, io.SeekStart); err != nil {
+		_ = wal.Close()
+		return false, fmt.Errorf("could not seek log: %w", err)
+	}
+
+	p.wal = wal
+	p.records = records
+	p.lastSync = time.Now()
+
+	return snapshotFound || records > 0, nil
+}
+
+func (p *Persistence[K]) replaySnapshot(apply func(operation, K) error) (bool, error) {
+	f, err := os.Open(filepath.Join(p.Dir, snapshotFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	} else if err != nil {
+		return false, fmt.Errorf("could not open snapshot: %w", err)
+	}
+	defer f.Close()
+
+	// snapshots are renamed into place once complete, so a short read is a corruption too
+	if _, _, err = p.replay(f, apply); errors.Is(err, io.ErrUnexpectedEOF) {
+		err = fmt.Errorf("%w: truncated snapshot", ErrCorruptedLog)
+	}
+	if err != nil {
+		return false, fmt.Errorf("could not replay snapshot: %w", err)
+	}
+
+	return true, nil
+}
+
+// replay applies every record of r and returns the offset after the last valid record.
+func (p *Persistence[K]) replay(r io.Reader, apply func(operation, K) error) (offset int64, records int, err error) {
+	br := bufio.NewReader(r)
+	for {
+		body, err := frame.Read(br)
+		if errors.Is(err, io.EOF) {
+			return offset, records, nil
+		} else if errors.Is(err, frame.ErrCorrupted) {
+			return offset, records, fmt.Errorf("%w at offset %d: %v", ErrCorruptedLog, offset, err)
+		} else if err != nil {
+			return offset, records, err
+		}
+
+		var e K
+		if e, err = p.codec.Unmarshal(body[1:]); err != nil {
+			return offset, records, fmt.Errorf("%w: could not decode record at offset %d: %v", ErrCorruptedLog, offset, err)
+		}
+		if err = apply(operation(body[0]), e); err != nil {
+			return offset, records, fmt.Errorf("could not apply record at offset %d: %w", offset, err)
+		}
+
+		offset += int64(frame.HeaderSize + len(body))
+		records++
+	}
+}
+
+func (p *Persistence[K]) append(op operation, e K) error {
+	if p.wal == nil {
+		return ErrPersistenceNotStarted
+	}
+
+	record, err := p.encode(op, e)
+	if err != nil {
+		return err
+	}
+	if _, err = p.wal.Write(record); err != nil {
+		return fmt.Errorf("could not write log: %w", err)
+	}
+	p.records++
+
+	switch p.SyncPolicy {
+	case SyncAlways:
+		return p.sync()
+	case SyncInterval:
+		if time.Since(p.lastSync) >= p.SyncInterval {
+			return p.sync()
+		}
+	}
+
+	return nil
+}
+
+func (p *Persistence[K]) shouldCompact() bool {
+	return p.SnapshotEvery > 0 && p.records >= p.SnapshotEvery
+}
+
+// compact writes the collection to a new snapshot and truncates the log.
+func (p *Persistence[K]) compact(col []K) error {
+	if p.wal == nil {
+		return ErrPersistenceNotStarted
+	}
+
+	tmp := filepath.Join(p.Dir, snapshotFile+".tmp")
+	f, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o644)
+	if err != nil {
+		return fmt.Errorf("could not create snapshot: %w", err)
+	}
+
+	bw := bufio.NewWriter(f)
+	for _, e := range col {
+		record, err := p.encode(opCreate, e)
+		if err != nil {
+			_ = f.Close()
+			return err
+		}
+		if _, err = bw.Write(record); err != nil {
+			_ = f.Close()
+			return fmt.Errorf("could not write snapshot: %w", err)
+		}
+	}
+	if err = bw.Flush(); err == nil {
+		err = f.Sync()
+	}
+	if cErr := f.Close(); err == nil {
+		err = cErr
+	}
+	if err != nil {
+		return fmt.Errorf("could not write snapshot: %w", err)
+	}
+
+	if err = os.Rename(tmp, filepath.Join(p.Dir, snapshotFile)); err != nil {
+		return fmt.Errorf("could not replace snapshot: %w", err)
+	}
+	if err = syncDir(p.Dir); err != nil {
+		return err
+	}
+
+	if err = p.wal.Truncate(0); err != nil {
+		return fmt.Errorf("could not truncate log: %w", err)
+	}
+	if _, err = p.wal.Seek(0, io.SeekStart); err != nil {
+		return fmt.Errorf("could not seek log: %w", err)
+	}
+	p.records = 0
+
+	return p.sync()
+}
+
+func (p *Persistence[K]) close() error {
+	if p.wal == nil {
+		return nil
+	}
+
+	err := p.wal.Sync()
+	if cErr := p.wal.Close(); err == nil {
+		err = cErr
+	}
+	p.wal = nil
+
+	return err
+}
+
+func (p *Persistence[K]) sync() error {
+	if err := p.wal.Sync(); err != nil {
+		return fmt.Errorf("could not sync log: %w", err)
+	}
+	p.lastSync = time.Now()
+
+	return nil
+}
+
+func (p *Persistence[K]) encode(op operation, e K) ([]byte, error) {
+	payload, err := p.codec.Marshal(e)
+	if err != nil {
+		return nil, fmt.Errorf("could not encode entity: %w", err)
+	}
+
+	record, err := frame.Encode(append([]byte{byte(op)}, payload...))
+	if err != nil {
+		return nil, fmt.Errorf("could not encode record: %w", err)
+	}
+
+	return record, nil
+}
+
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return fmt.Errorf("could not open persistence dir: %w", err)
+	}
+	defer d.Close()
+
+	if err = d.Sync(); err != nil {
+		return fmt.Errorf("could not sync persistence dir: %w", err)
+	}
+
+	return nil
+}
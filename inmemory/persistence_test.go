@@ -0,0 +1,208 @@
+package inmemory_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/davfer/crudo/inmemory"
+)
+
+func newPersistentRepository(t *testing.T, dir string, snapshotEvery int) *inmemory.Repository[*testMemoEntity] {
+	t.Helper()
+
+	r := inmemory.NewRepository[*testMemoEntity](nil, inmemory.WithPersistence(inmemory.NewPersistence[*testMemoEntity](
+		dir,
+		inmemory.WithSnapshotEvery[*testMemoEntity](snapshotEvery),
+	)))
+	if err := r.Start(context.TODO(), nil); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+
+	return r
+}
+
+func TestPersistence_Restore(t *testing.T) {
+	tests := []struct {
+		name          string
+		snapshotEvery int
+	}{
+		{name: "Test restore from log", snapshotEvery: 0},
+		{name: "Test restore from snapshot and log", snapshotEvery: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.TODO()
+			dir := t.TempDir()
+
+			r := newPersistentRepository(t, dir, tt.snapshotEvery)
+			for _, e := range []*testMemoEntity{
+				{Id: "1", Attr1: "attr1"},
+				{Id: "2", Attr1: "attr2"},
+				{Id: "3", Attr1: "attr3"},
+			} {
+				if _, err := r.Create(ctx, e); err != nil {
+					t.Fatalf("Create() error = %v", err)
+				}
+			}
+			if err := r.Update(ctx, &testMemoEntity{Id: "2", Attr1: "attr2 updated"}); err != nil {
+				t.Fatalf("Update() error = %v", err)
+			}
+			if err := r.Delete(ctx, &testMemoEntity{Id: "1"}); err != nil {
+				t.Fatalf("Delete() error = %v", err)
+			}
+			if err := r.Close(); err != nil {
+				t.Fatalf("Close() error = %v", err)
+			}
+
+			restored := newPersistentRepository(t, dir, tt.snapshotEvery)
+			defer restored.Close()
+
+			want := []*testMemoEntity{
+				{Id: "2", Attr1: "attr2 updated"},
+				{Id: "3", Attr1: "attr3"},
+			}
+			if got, _ := restored.ReadAll(ctx); !reflect.DeepEqual(got, want) {
+				t.Errorf("ReadAll() got = %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestPersistence_Bootstrap(t *testing.T) {
+	ctx := context.TODO()
+	dir := t.TempDir()
+
+	var calls int
+	onBootstrap := func(ctx context.Context) error {
+		calls++
+		return nil
+	}
+
+	r := inmemory.NewRepository(
+		[]*testMemoEntity{{Id: "1", Attr1: "seed"}},
+		inmemory.WithPersistence(inmemory.NewPersistence[*testMemoEntity](dir)),
+	)
+	if err := r.Start(ctx, onBootstrap); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	_ = r.Close()
+
+	restored := inmemory.NewRepository(nil, inmemory.WithPersistence(inmemory.NewPersistence[*testMemoEntity](dir)))
+	if err := restored.Start(ctx, onBootstrap); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	defer restored.Close()
+
+	if calls != 1 {
+		t.Errorf("Start() onBootstrap calls = %d, want 1", calls)
+	}
+	if _, err := restored.Read(ctx, "1"); err != nil {
+		t.Errorf("Read() error = %v", err)
+	}
+}
+
+func TestPersistence_Corruption(t *testing.T) {
+	tests := []struct {
+		name    string
+		corrupt func(data []byte) []byte
+		want    int
+		wantErr error
+	}{
+		{
+			name: "Test torn tail is dropped",
+			corrupt: func(data []byte) []byte {
+				return append(data, 42, 0, 0)
+			},
+			want: 2,
+		},
+		{
+			name: "Test checksum mismatch",
+			corrupt: func(data []byte) []byte {
+				data[len(data)-2] ^= 0xff
+				return data
+			},
+			wantErr: inmemory.ErrCorruptedLog,
+		},
+		{
+			// the record would reach past the end of the log, it must not pass for a torn tail
+			name: "Test corrupted length",
+			corrupt: func(data []byte) []byte {
+				data[2] ^= 0x01
+				return data
+			},
+			wantErr: inmemory.ErrCorruptedLog,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.TODO()
+			dir := t.TempDir()
+
+			r := newPersistentRepository(t, dir, 0)
+			_, _ = r.Create(ctx, &testMemoEntity{Id: "1"})
+			_, _ = r.Create(ctx, &testMemoEntity{Id: "2"})
+			_ = r.Close()
+
+			wal := filepath.Join(dir, "wal.log")
+			data, err := os.ReadFile(wal)
+			if err != nil {
+				t.Fatalf("ReadFile() error = %v", err)
+			}
+			if err = os.WriteFile(wal, tt.corrupt(data), 0o644); err != nil {
+				t.Fatalf("WriteFile() error = %v", err)
+			}
+
+			restored := inmemory.NewRepository(nil, inmemory.WithPersistence(inmemory.NewPersistence[*testMemoEntity](dir)))
+			err = restored.Start(ctx, nil)
+			defer restored.Close()
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Start() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil {
+				return
+			}
+
+			if got, _ := restored.ReadAll(ctx); len(got) != tt.want {
+				t.Errorf("ReadAll() got = %d entities, want %d", len(got), tt.want)
+			}
+			if _, err = restored.Create(ctx, &testMemoEntity{Id: "3"}); err != nil {
+				t.Errorf("Create() error = %v", err)
+			}
+		})
+	}
+}
+
+func TestPersistence_InterruptedCompaction(t *testing.T) {
+	ctx := context.TODO()
+	dir := t.TempDir()
+
+	r := newPersistentRepository(t, dir, 0)
+	_, _ = r.Create(ctx, &testMemoEntity{Id: "1", Attr1: "attr1"})
+	_, _ = r.Create(ctx, &testMemoEntity{Id: "2", Attr1: "attr2"})
+	_ = r.Update(ctx, &testMemoEntity{Id: "2", Attr1: "attr2 updated"})
+	_ = r.Close()
+
+	// crash after the snapshot is renamed into place and before the log is truncated
+	data, err := os.ReadFile(filepath.Join(dir, "wal.log"))
+	if err != nil {
+		t.Fatalf("ReadFile() error = %v", err)
+	}
+	if err = os.WriteFile(filepath.Join(dir, "snapshot.db"), data, 0o644); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+
+	restored := newPersistentRepository(t, dir, 0)
+	defer restored.Close()
+
+	want := []*testMemoEntity{
+		{Id: "1", Attr1: "attr1"},
+		{Id: "2", Attr1: "attr2 updated"},
+	}
+	if got, _ := restored.ReadAll(ctx); !reflect.DeepEqual(got, want) {
+		t.Errorf("ReadAll() got = %v, want %v", got, want)
+	}
+}
@@ -10,6 +10,8 @@ type Policy[K entity.Entity] interface {
 	ApplyCreate(ctx context.Context, e K, col []K) ([]K, error)
 }
 
+// PolicyMRU keeps the most recently created entities, evicting the oldest created one when the capacity is reached.
+// Reads are not taken into account, see PolicyLeastRecentlyUsed.
 type PolicyMRU[K entity.Entity] struct {
 	Capacity int
 }
@@ -22,6 +24,8 @@ func (p PolicyMRU[K]) ApplyCreate(ctx context.Context, e K, col []K) ([]K, error
 	return append(col, e), nil
 }
 
+// PolicyLRU keeps the first entities created, refusing new ones once the capacity is reached. Despite its
+// name nothing is evicted, see PolicyLeastRecentlyUsed for a least recently used cache.
 type PolicyLRU[K entity.Entity] struct {
 	Capacity int
 }
@@ -33,3 +37,36 @@ func (p PolicyLRU[K]) ApplyCreate(ctx context.Context, e K, col []K) ([]K, error
 
 	return append(col, e), nil
 }
+
+// AccessPolicy is an optional extension of a Policy told about every read, e.g. to keep track of recency. Reads are not
+// persisted, so a repository with persistence rejects it.
+type AccessPolicy[K entity.Entity] interface {
+	ApplyRead(ctx context.Context, e K, col []K) []K
+}
+
+// PolicyLeastRecentlyUsed evicts the least recently created or read entity when the capacity is reached, a capacity of
+// zero or less is unbounded.
+type PolicyLeastRecentlyUsed[K entity.Entity] struct {
+	Capacity int
+}
+
+func (p PolicyLeastRecentlyUsed[K]) ApplyCreate(ctx context.Context, e K, col []K) ([]K, error) {
+	if p.Capacity > 0 && len(col) >= p.Capacity {
+		col = col[1:]
+	}
+
+	return append(col, e), nil
+}
+
+func (p PolicyLeastRecentlyUsed[K]) ApplyRead(ctx context.Context, e K, col []K) []K {
+	for i, c := range col {
+		if c.GetID() == e.GetID() {
+			// most recently used entities live at the end of the collection
+			copy(col[i:], col[i+1:])
+			col[len(col)-1] = e
+			break
+		}
+	}
+
+	return col
+}
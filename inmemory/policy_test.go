@@ -0,0 +1,83 @@
+package inmemory_test
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/davfer/crudo/entity"
+	"github.com/davfer/crudo/inmemory"
+)
+
+func TestPolicyLeastRecentlyUsed(t *testing.T) {
+	ctx := context.TODO()
+	r := inmemory.NewRepository([]*testMemoEntity{}, inmemory.WithPolicy[*testMemoEntity](inmemory.PolicyLeastRecentlyUsed[*testMemoEntity]{Capacity: 2}))
+
+	_, _ = r.Create(ctx, &testMemoEntity{Id: "1"})
+	_, _ = r.Create(ctx, &testMemoEntity{Id: "2"})
+	if _, err := r.Read(ctx, "1"); err != nil {
+		t.Fatalf("Read() error = %v", err)
+	}
+	_, _ = r.Create(ctx, &testMemoEntity{Id: "3"})
+
+	if _, err := r.Read(ctx, "2"); !errors.Is(err, entity.ErrEntityNotFound) {
+		t.Errorf("Read() evicted error = %v, want %v", err, entity.ErrEntityNotFound)
+	}
+
+	var ids []string
+	all, _ := r.ReadAll(ctx)
+	for _, e := range all {
+		ids = append(ids, e.Id)
+	}
+	if !reflect.DeepEqual(ids, []string{"1", "3"}) {
+		t.Errorf("ReadAll() ids = %v, want [1 3]", ids)
+	}
+}
+
+func TestPolicy_OnEvict(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy inmemory.Policy[*testMemoEntity]
+		want   []string
+	}{
+		{name: "Test MRU", policy: inmemory.PolicyMRU[*testMemoEntity]{Capacity: 2}, want: []string{"1"}},
+		{name: "Test LRU", policy: inmemory.PolicyLRU[*testMemoEntity]{Capacity: 2}, want: []string{"3"}},
+		{name: "Test least recently used", policy: inmemory.PolicyLeastRecentlyUsed[*testMemoEntity]{Capacity: 2}, want: []string{"2"}},
+		{name: "Test unbounded", policy: inmemory.PolicyLeastRecentlyUsed[*testMemoEntity]{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.TODO()
+			var evicted []string
+			r := inmemory.NewRepository([]*testMemoEntity{},
+				inmemory.WithPolicy(tt.policy),
+				inmemory.WithOnEvict(func(e *testMemoEntity) {
+					evicted = append(evicted, e.Id)
+				}),
+			)
+
+			_, _ = r.Create(ctx, &testMemoEntity{Id: "1"})
+			_, _ = r.Create(ctx, &testMemoEntity{Id: "2"})
+			_, _ = r.Read(ctx, "1")
+			if _, err := r.Create(ctx, &testMemoEntity{Id: "3"}); err != nil {
+				t.Fatalf("Create() error = %v", err)
+			}
+
+			if !reflect.DeepEqual(evicted, tt.want) {
+				t.Errorf("evicted = %v, want %v", evicted, tt.want)
+			}
+		})
+	}
+}
+
+func TestPolicy_AccessPersisted(t *testing.T) {
+	r := inmemory.NewRepository([]*testMemoEntity{},
+		inmemory.WithPolicy[*testMemoEntity](inmemory.PolicyLeastRecentlyUsed[*testMemoEntity]{Capacity: 2}),
+		inmemory.WithPersistence(inmemory.NewPersistence[*testMemoEntity](t.TempDir())),
+	)
+
+	if err := r.Start(context.TODO(), nil); err == nil {
+		t.Errorf("Start() error = nil, want access policy rejected")
+	}
+}
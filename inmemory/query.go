@@ -0,0 +1,182 @@
+package inmemory
+
+import (
+	"cmp"
+	"fmt"
+	"reflect"
+	"slices"
+	"time"
+
+	"github.com/davfer/crudo/entity"
+	"github.com/davfer/go-specification"
+)
+
+type Order string
+
+const (
+	OrderAsc  Order = "asc"
+	OrderDesc Order = "desc"
+)
+
+type Sort[K entity.Entity] struct {
+	Field   string           // Field is the exported struct field to sort by, compared through reflection
+	Compare func(a, b K) int // Compare is an accessor based comparison used instead of Field when set
+	Order   Order
+}
+
+// Query decorates a criteria with sorting and projection. It satisfies specification.Criteria so it can be passed to
+// Match and MatchOne of the in-memory repositories, other repositories will not understand it.
+type Query[K entity.Entity] struct {
+	Criteria specification.Criteria // Criteria filters the entities, nil matches all of them
+	Sort     []Sort[K]              // Sort keys applied in order with a stable sort
+	Fields   []string               // Fields projects the results to the given exported fields, the ID is always kept
+}
+
+func (q Query[K]) IsSatisfiedBy(v any) bool {
+	if q.Criteria == nil {
+		return true
+	}
+
+	return q.Criteria.IsSatisfiedBy(v)
+}
+
+// apply sorts and projects the matched entities, returning new entities when a projection is requested.
+func (q Query[K]) apply(ks []K) ([]K, error) {
+	if len(ks) == 0 {
+		return ks, nil
+	}
+
+	if len(q.Sort) > 0 {
+		compare, err := q.comparator()
+		if err != nil {
+			return nil, err
+		}
+		slices.SortStableFunc(ks, compare)
+	}
+
+	if len(q.Fields) == 0 {
+		return ks, nil
+	}
+
+	projected := make([]K, len(ks))
+	for i, k := range ks {
+		p, err := project(k, q.Fields)
+		if err != nil {
+			return nil, err
+		}
+		projected[i] = p
+	}
+
+	return projected, nil
+}
+
+func (q Query[K]) comparator() (func(a, b K) int, error) {
+	compares := make([]func(a, b K) int, len(q.Sort))
+	for i, s := range q.Sort {
+		compare := s.Compare
+		if compare == nil {
+			byField, err := fieldComparator[K](s.Field)
+			if err != nil {
+				return nil, err
+			}
+			compare = byField
+		}
+		if s.Order == OrderDesc {
+			asc := compare
+			compare = func(a, b K) int {
+				return asc(b, a)
+			}
+		}
+
+		compares[i] = compare
+	}
+
+	return func(a, b K) int {
+		for _, compare := range compares {
+			if c := compare(a, b); c != 0 {
+				return c
+			}
+		}
+		return 0
+	}, nil
+}
+
+func fieldComparator[K entity.Entity](name string) (func(a, b K) int, error) {
+	t := reflect.TypeFor[K]()
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("cannot sort %s by field %s", t, name)
+	}
+
+	f, ok := t.FieldByName(name)
+	if !ok || !f.IsExported() {
+		return nil, fmt.Errorf("unknown sort field %s", name)
+	}
+
+	var compare func(a, b reflect.Value) int
+	switch f.Type.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		compare = func(a, b reflect.Value) int { return cmp.Compare(a.Int(), b.Int()) }
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		compare = func(a, b reflect.Value) int { return cmp.Compare(a.Uint(), b.Uint()) }
+	case reflect.Float32, reflect.Float64:
+		compare = func(a, b reflect.Value) int { return cmp.Compare(a.Float(), b.Float()) }
+	case reflect.String:
+		compare = func(a, b reflect.Value) int { return cmp.Compare(a.String(), b.String()) }
+	case reflect.Bool:
+		compare = func(a, b reflect.Value) int { return cmp.Compare(boolToInt(a.Bool()), boolToInt(b.Bool())) }
+	default:
+		if f.Type != reflect.TypeFor[time.Time]() {
+			return nil, fmt.Errorf("field %s of type %s is not sortable", name, f.Type)
+		}
+		compare = func(a, b reflect.Value) int {
+			return a.Interface().(time.Time).Compare(b.Interface().(time.Time))
+		}
+	}
+
+	return func(a, b K) int {
+		return compare(
+			reflect.Indirect(reflect.ValueOf(a)).FieldByIndex(f.Index),
+			reflect.Indirect(reflect.ValueOf(b)).FieldByIndex(f.Index),
+		)
+	}, nil
+}
+
+// project returns a new entity holding only the given fields and the ID of k.
+func project[K entity.Entity](k K, fields []string) (p K, err error) {
+	src := reflect.ValueOf(k)
+	isPtr := src.Kind() == reflect.Pointer
+	src = reflect.Indirect(src)
+	if src.Kind() != reflect.Struct {
+		return p, fmt.Errorf("cannot project %s", src.Type())
+	}
+
+	dst := reflect.New(src.Type())
+	for _, name := range fields {
+		f, ok := src.Type().FieldByName(name)
+		if !ok || !f.IsExported() {
+			return p, fmt.Errorf("unknown projection field %s", name)
+		}
+		dst.Elem().FieldByIndex(f.Index).Set(src.FieldByIndex(f.Index))
+	}
+
+	if isPtr {
+		p = dst.Interface().(K)
+		if err = p.SetID(k.GetID()); err != nil {
+			return p, fmt.Errorf("could not set projected entity id: %w", err)
+		}
+		return p, nil
+	}
+
+	// value entities cannot keep a SetID call, the caller must project the ID field explicitly
+	return dst.Elem().Interface().(K), nil
+}
+
+func boolToInt(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,111 @@
+package inmemory_test
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/davfer/crudo/inmemory"
+	"github.com/davfer/go-specification"
+)
+
+func TestRepository_MatchQuery(t *testing.T) {
+	collection := func() []*testMemoEntity {
+		return []*testMemoEntity{
+			{Id: "1", Attr1: "b", SomeNiceField: "x"},
+			{Id: "2", Attr1: "a", SomeNiceField: "y"},
+			{Id: "3", Attr1: "b", SomeNiceField: "z"},
+			{Id: "4", Attr1: "a", SomeNiceField: "x"},
+		}
+	}
+	tests := []struct {
+		name    string
+		q       inmemory.Query[*testMemoEntity]
+		want    []*testMemoEntity
+		wantErr bool
+	}{
+		{
+			name: "Test sort by field is stable",
+			q: inmemory.Query[*testMemoEntity]{
+				Sort: []inmemory.Sort[*testMemoEntity]{{Field: "Attr1"}},
+			},
+			want: []*testMemoEntity{
+				{Id: "2", Attr1: "a", SomeNiceField: "y"},
+				{Id: "4", Attr1: "a", SomeNiceField: "x"},
+				{Id: "1", Attr1: "b", SomeNiceField: "x"},
+				{Id: "3", Attr1: "b", SomeNiceField: "z"},
+			},
+		},
+		{
+			name: "Test sort multi key desc with accessor",
+			q: inmemory.Query[*testMemoEntity]{
+				Criteria: specification.Attr{Name: "Id", Value: "3", Comparison: specification.ComparisonNe},
+				Sort: []inmemory.Sort[*testMemoEntity]{
+					{Field: "Attr1", Order: inmemory.OrderDesc},
+					{Compare: func(a, b *testMemoEntity) int { return strings.Compare(a.SomeNiceField, b.SomeNiceField) }, Order: inmemory.OrderDesc},
+				},
+			},
+			want: []*testMemoEntity{
+				{Id: "1", Attr1: "b", SomeNiceField: "x"},
+				{Id: "2", Attr1: "a", SomeNiceField: "y"},
+				{Id: "4", Attr1: "a", SomeNiceField: "x"},
+			},
+		},
+		{
+			name: "Test projection",
+			q: inmemory.Query[*testMemoEntity]{
+				Sort:   []inmemory.Sort[*testMemoEntity]{{Field: "Id", Order: inmemory.OrderDesc}},
+				Fields: []string{"SomeNiceField"},
+			},
+			want: []*testMemoEntity{
+				{Id: "4", SomeNiceField: "x"},
+				{Id: "3", SomeNiceField: "z"},
+				{Id: "2", SomeNiceField: "y"},
+				{Id: "1", SomeNiceField: "x"},
+			},
+		},
+		{
+			name:    "Test unknown sort field",
+			q:       inmemory.Query[*testMemoEntity]{Sort: []inmemory.Sort[*testMemoEntity]{{Field: "Missing"}}},
+			wantErr: true,
+		},
+		{
+			name:    "Test unknown projection field",
+			q:       inmemory.Query[*testMemoEntity]{Fields: []string{"missing"}},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		for _, r := range []interface {
+			Match(context.Context, specification.Criteria) ([]*testMemoEntity, error)
+		}{
+			inmemory.NewRepository(collection()),
+			inmemory.NewShardedRepository(collection(), 3),
+		} {
+			t.Run(tt.name, func(t *testing.T) {
+				got, err := r.Match(context.TODO(), tt.q)
+				if (err != nil) != tt.wantErr {
+					t.Fatalf("Match() error = %v, wantErr %v", err, tt.wantErr)
+				}
+				if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+					t.Errorf("Match() got = %v, want %v", got, tt.want)
+				}
+			})
+		}
+	}
+}
+
+func TestRepository_MatchQueryKeepsCollection(t *testing.T) {
+	r := inmemory.NewRepository([]*testMemoEntity{{Id: "2", Attr1: "a"}, {Id: "1", Attr1: "b"}})
+
+	_, _ = r.Match(context.TODO(), inmemory.Query[*testMemoEntity]{
+		Sort:   []inmemory.Sort[*testMemoEntity]{{Field: "Id"}},
+		Fields: []string{"Id"},
+	})
+
+	want := []*testMemoEntity{{Id: "2", Attr1: "a"}, {Id: "1", Attr1: "b"}}
+	if !reflect.DeepEqual(r.Collection, want) {
+		t.Errorf("Match() changed collection = %v, want %v", r.Collection, want)
+	}
+}
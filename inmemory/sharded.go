@@ -0,0 +1,176 @@
+package inmemory
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"path/filepath"
+
+	"github.com/davfer/archit/patterns/opts"
+	"github.com/davfer/crudo/entity"
+	"github.com/davfer/go-specification"
+	"github.com/google/uuid"
+)
+
+// ShardedRepository partitions the entities by hashed ID across independently locked repositories, so writes to
+// different shards do not contend. Options apply to every shard, policy capacities are therefore per shard and
+// persistence is kept in one subdirectory per shard.
+type ShardedRepository[K entity.Entity] struct {
+	shards     []*Repository[K]
+	idStrategy IdStrategy[K]
+}
+
+func NewShardedRepository[K entity.Entity](c []K, shards int, o ...opts.Opt[Repository[K]]) *ShardedRepository[K] {
+	if shards < 1 {
+		shards = 1
+	}
+
+	s := &ShardedRepository[K]{
+		shards: make([]*Repository[K], shards),
+	}
+	for i := range s.shards {
+		shard := NewRepository[K](nil, o...)
+		// ids are assigned before routing, a shard must keep them
+		s.idStrategy = shard.idStrategy
+		shard.idStrategy = nil
+		if shard.persistence != nil {
+			p := *shard.persistence
+			p.Dir = filepath.Join(p.Dir, fmt.Sprintf("shard-%d", i))
+			shard.persistence = &p
+		}
+
+		s.shards[i] = shard
+	}
+	for _, e := range c {
+		shard := s.shard(e.GetID())
+		shard.Collection = append(shard.Collection, e)
+	}
+
+	return s
+}
+
+func (r *ShardedRepository[K]) Start(ctx context.Context, onBootstrap func(ctx context.Context) error) error {
+	bootstraps := 0
+	for i, shard := range r.shards {
+		err := shard.Start(ctx, func(ctx context.Context) error {
+			bootstraps++
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("could not start shard %d: %w", i, err)
+		}
+	}
+
+	if bootstraps == len(r.shards) && onBootstrap != nil {
+		return onBootstrap(ctx)
+	}
+
+	return nil
+}
+
+// Close releases the persistence files of every shard.
+func (r *ShardedRepository[K]) Close() error {
+	var errs []error
+	for _, shard := range r.shards {
+		errs = append(errs, shard.Close())
+	}
+
+	return errors.Join(errs...)
+}
+
+func (r *ShardedRepository[K]) Create(ctx context.Context, e K) (K, error) {
+	if r.idStrategy != nil {
+		id := r.idStrategy.Generate(e)
+
+		err := e.SetID(id)
+		if err != nil {
+			return e, fmt.Errorf("error setting generated entity id: %w", err)
+		}
+	} else if e.GetID().IsEmpty() {
+		err := e.SetID(entity.NewIDFromString(uuid.New().String()))
+		if err != nil {
+			return e, fmt.Errorf("error setting entity id: %w", err)
+		}
+	}
+
+	return r.shard(e.GetID()).Create(ctx, e)
+}
+
+func (r *ShardedRepository[K]) Read(ctx context.Context, id entity.ID) (K, error) {
+	return r.shard(id).Read(ctx, id)
+}
+
+func (r *ShardedRepository[K]) Match(ctx context.Context, c specification.Criteria) ([]K, error) {
+	// queries are sorted and projected once the shards are merged
+	q, isQuery := c.(Query[K])
+	if isQuery {
+		c = Query[K]{Criteria: q.Criteria}
+	}
+
+	var result []K
+	for _, shard := range r.shards {
+		ks, err := shard.Match(ctx, c)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, ks...)
+	}
+
+	if isQuery {
+		return q.apply(result)
+	}
+
+	return result, nil
+}
+
+func (r *ShardedRepository[K]) MatchOne(ctx context.Context, c specification.Criteria) (k K, err error) {
+	if _, ok := c.(Query[K]); ok {
+		ks, err := r.Match(ctx, c)
+		if err != nil {
+			return k, err
+		}
+		if len(ks) == 0 {
+			return k, entity.ErrEntityNotFound
+		}
+
+		return ks[0], nil
+	}
+
+	for _, shard := range r.shards {
+		k, err = shard.MatchOne(ctx, c)
+		if !errors.Is(err, entity.ErrEntityNotFound) {
+			return
+		}
+	}
+
+	return k, entity.ErrEntityNotFound
+}
+
+func (r *ShardedRepository[K]) ReadAll(ctx context.Context) ([]K, error) {
+	result := []K{}
+	for _, shard := range r.shards {
+		ks, err := shard.ReadAll(ctx)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, ks...)
+	}
+
+	return result, nil
+}
+
+func (r *ShardedRepository[K]) Update(ctx context.Context, e K) error {
+	return r.shard(e.GetID()).Update(ctx, e)
+}
+
+func (r *ShardedRepository[K]) Delete(ctx context.Context, e K) error {
+	return r.shard(e.GetID()).Delete(ctx, e)
+}
+
+func (r *ShardedRepository[K]) shard(id entity.ID) *Repository[K] {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(id))
+
+	return r.shards[h.Sum32()%uint32(len(r.shards))]
+}
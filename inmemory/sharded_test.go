@@ -0,0 +1,128 @@
+package inmemory_test
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+	"sync/atomic"
+	"testing"
+
+	"github.com/davfer/crudo"
+	"github.com/davfer/crudo/entity"
+	"github.com/davfer/crudo/inmemory"
+	"github.com/davfer/go-specification"
+)
+
+var _ crudo.Repository[*testMemoEntity] = (*inmemory.ShardedRepository[*testMemoEntity])(nil)
+
+func TestShardedRepository_Contract(t *testing.T) {
+	ctx := context.TODO()
+	r := inmemory.NewShardedRepository([]*testMemoEntity{
+		{Id: "seed", Attr1: "attr1"},
+	}, 4)
+
+	bootstrapped := false
+	if err := r.Start(ctx, func(ctx context.Context) error {
+		bootstrapped = true
+		return nil
+	}); err != nil || !bootstrapped {
+		t.Fatalf("Start() error = %v, bootstrapped %v", err, bootstrapped)
+	}
+
+	for i := 0; i < 20; i++ {
+		if _, err := r.Create(ctx, &testMemoEntity{Id: fmt.Sprintf("%d", i), Attr1: fmt.Sprintf("attr%d", i%2)}); err != nil {
+			t.Fatalf("Create() error = %v", err)
+		}
+	}
+	if _, err := r.Create(ctx, &testMemoEntity{Id: "seed"}); err != entity.ErrEntityAlreadyExists {
+		t.Errorf("Create() error = %v, want %v", err, entity.ErrEntityAlreadyExists)
+	}
+	generated, err := r.Create(ctx, &testMemoEntity{Attr1: "generated"})
+	if err != nil || generated.GetID().IsEmpty() {
+		t.Fatalf("Create() error = %v, id %v", err, generated.GetID())
+	}
+	if got, err := r.Read(ctx, generated.GetID()); err != nil || got.Attr1 != "generated" {
+		t.Errorf("Read() got = %v, error = %v", got, err)
+	}
+
+	if err = r.Update(ctx, &testMemoEntity{Id: "3", Attr1: "updated"}); err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+	if err = r.Delete(ctx, &testMemoEntity{Id: "4"}); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	if _, err = r.Read(ctx, "4"); err != entity.ErrEntityNotFound {
+		t.Errorf("Read() error = %v, want %v", err, entity.ErrEntityNotFound)
+	}
+
+	matched, _ := r.Match(ctx, specification.Attr{Name: "Attr1", Value: "attr1", Comparison: specification.ComparisonEq})
+	var ids []string
+	for _, e := range matched {
+		ids = append(ids, e.Id)
+	}
+	sort.Strings(ids)
+	want := []string{"1", "11", "13", "15", "17", "19", "5", "7", "9", "seed"}
+	if !reflect.DeepEqual(ids, want) {
+		t.Errorf("Match() got = %v, want %v", ids, want)
+	}
+
+	one, err := r.MatchOne(ctx, specification.Attr{Name: "Attr1", Value: "updated", Comparison: specification.ComparisonEq})
+	if err != nil || one.Id != "3" {
+		t.Errorf("MatchOne() got = %v, error = %v", one, err)
+	}
+	if _, err = r.MatchOne(ctx, specification.Attr{Name: "Attr1", Value: "none", Comparison: specification.ComparisonEq}); err != entity.ErrEntityNotFound {
+		t.Errorf("MatchOne() error = %v, want %v", err, entity.ErrEntityNotFound)
+	}
+
+	if all, _ := r.ReadAll(ctx); len(all) != 21 {
+		t.Errorf("ReadAll() got = %d entities, want 21", len(all))
+	}
+}
+
+func TestShardedRepository_Persistence(t *testing.T) {
+	ctx := context.TODO()
+	dir := t.TempDir()
+	newRepo := func() *inmemory.ShardedRepository[*testMemoEntity] {
+		return inmemory.NewShardedRepository[*testMemoEntity](nil, 3, inmemory.WithPersistence(inmemory.NewPersistence[*testMemoEntity](dir)))
+	}
+
+	r := newRepo()
+	if err := r.Start(ctx, nil); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	for i := 0; i < 10; i++ {
+		_, _ = r.Create(ctx, &testMemoEntity{Id: fmt.Sprintf("%d", i)})
+	}
+	_ = r.Close()
+
+	restored := newRepo()
+	if err := restored.Start(ctx, func(ctx context.Context) error {
+		t.Errorf("Start() unexpected bootstrap")
+		return nil
+	}); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	defer restored.Close()
+
+	if all, _ := restored.ReadAll(ctx); len(all) != 10 {
+		t.Errorf("ReadAll() got = %d entities, want 10", len(all))
+	}
+}
+
+func BenchmarkShardedRepository_ConcurrentCreate(b *testing.B) {
+	for _, shards := range []int{1, 16} {
+		b.Run(fmt.Sprintf("shards=%d", shards), func(b *testing.B) {
+			ctx := context.TODO()
+			r := inmemory.NewShardedRepository[*testMemoEntity](nil, shards)
+			var seq atomic.Int64
+
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					_, _ = r.Create(ctx, &testMemoEntity{Id: fmt.Sprintf("%d", seq.Add(1))})
+				}
+			})
+		})
+	}
+}
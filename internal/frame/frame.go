@@ -0,0 +1,65 @@
+// Package frame encodes the records of the append-only files, each one prefixed by a header holding its length, the
+// checksum of its body and the checksum of the header itself.
+package frame
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+)
+
+var ErrCorrupted = errors.New("corrupted frame")
+
+const (
+	HeaderSize = 12
+	MaxSize    = 64 << 20 // MaxSize of a frame body
+)
+
+// Encode returns body framed.
+func Encode(body []byte) ([]byte, error) {
+	if len(body) == 0 || len(body) > MaxSize {
+		return nil, fmt.Errorf("could not frame %d bytes, size must be within 1 and %d", len(body), MaxSize)
+	}
+
+	f := make([]byte, HeaderSize+len(body))
+	binary.LittleEndian.PutUint32(f[0:4], uint32(len(body)))
+	binary.LittleEndian.PutUint32(f[4:8], crc32.ChecksumIEEE(body))
+	binary.LittleEndian.PutUint32(f[8:12], crc32.ChecksumIEEE(f[0:8]))
+	copy(f[HeaderSize:], body)
+
+	return f, nil
+}
+
+// Read returns the body of the next frame of r. It fails with io.EOF when r has no more frames, io.ErrUnexpectedEOF
+// when r ends within the last frame, e.g. a torn write, and ErrCorrupted otherwise. The length is checked before the
+// body is read, so a corrupted one never passes for a torn frame.
+func Read(r io.Reader) ([]byte, error) {
+	header := make([]byte, HeaderSize)
+	if _, err := io.ReadFull(r, header); errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return nil, err
+	} else if err != nil {
+		return nil, fmt.Errorf("could not read frame header: %w", err)
+	}
+
+	if crc32.ChecksumIEEE(header[0:8]) != binary.LittleEndian.Uint32(header[8:12]) {
+		return nil, fmt.Errorf("%w: header checksum mismatch", ErrCorrupted)
+	}
+	size := binary.LittleEndian.Uint32(header[0:4])
+	if size == 0 || size > MaxSize {
+		return nil, fmt.Errorf("%w: invalid size %d", ErrCorrupted, size)
+	}
+
+	body := make([]byte, size)
+	if _, err := io.ReadFull(r, body); errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return nil, io.ErrUnexpectedEOF
+	} else if err != nil {
+		return nil, fmt.Errorf("could not read frame body: %w", err)
+	}
+	if crc32.ChecksumIEEE(body) != binary.LittleEndian.Uint32(header[4:8]) {
+		return nil, fmt.Errorf("%w: body checksum mismatch", ErrCorrupted)
+	}
+
+	return body, nil
+}
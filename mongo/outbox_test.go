@@ -0,0 +1,123 @@
+package mongo_test
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/davfer/crudo/entity"
+	"github.com/davfer/crudo/mongo"
+	"github.com/davfer/crudo/notifier"
+	"github.com/davfer/crudo/outbox"
+	"github.com/testcontainers/testcontainers-go"
+	"github.com/testcontainers/testcontainers-go/modules/mongodb"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	mongo2 "go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type testOutboxEntity struct {
+	ID    primitive.ObjectID `bson:"_id"`
+	Attr1 string             `bson:"attr_1"`
+}
+
+func (t *testOutboxEntity) GetID() entity.ID {
+	return mongo.NewIDFromObjectID(t.ID)
+}
+
+func (t *testOutboxEntity) SetID(id entity.ID) error {
+	t.ID = mongo.ToMustObjectID(id)
+	return nil
+}
+
+func (t *testOutboxEntity) GetResourceID() (string, error) {
+	return t.Attr1, nil
+}
+
+func (t *testOutboxEntity) SetResourceID(s string) error {
+	t.Attr1 = s
+	return nil
+}
+
+func TestOutboxRepository(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// transactions need a replica set
+	mongodbContainer, err := mongodb.Run(ctx, "mongo:latest", mongodb.WithReplicaSet("rs0"))
+	defer func() {
+		if err := testcontainers.TerminateContainer(mongodbContainer); err != nil {
+			t.Fatalf("failed to terminate container: %s", err)
+		}
+	}()
+	if err != nil {
+		t.Fatalf("failed to start container: %s", err)
+	}
+	uri, err := mongodbContainer.ConnectionString(ctx)
+	if err != nil {
+		t.Fatalf("failed to get connection string: %s", err)
+	}
+
+	client, err := mongo2.Connect(ctx, options.Client().ApplyURI(uri))
+	if err != nil {
+		t.Fatalf("failed to connect to mongo: %s", err)
+	}
+	defer func() {
+		if err := client.Disconnect(ctx); err != nil {
+			t.Fatalf("failed to disconnect from mongo: %s", err)
+		}
+	}()
+	db := client.Database("test")
+	// collections cannot be created within a transaction on older servers
+	for _, name := range []string{"test", "outbox"} {
+		if err = db.CreateCollection(ctx, name); err != nil {
+			t.Fatalf("failed to create collection %s: %s", name, err)
+		}
+	}
+	store := mongo.NewOutboxStore(db.Collection("outbox"))
+	if err = store.Start(ctx); err != nil {
+		t.Fatalf("failed to start outbox: %s", err)
+	}
+	repo := mongo.NewOutboxRepository(mongo.NewMongoRepository[*testOutboxEntity](db.Collection("test")), store)
+
+	res, err := repo.Create(ctx, &testOutboxEntity{ID: primitive.NewObjectID(), Attr1: "test"})
+	if err != nil {
+		t.Fatalf("failed to create entity: %s", err)
+	}
+	if err = repo.Update(ctx, &testOutboxEntity{ID: res.ID, Attr1: "test2"}); err != nil {
+		t.Fatalf("failed to update entity: %s", err)
+	}
+	if err = repo.Delete(ctx, res); err != nil {
+		t.Fatalf("failed to delete entity: %s", err)
+	}
+
+	records, err := store.Pending(ctx, 0)
+	if err != nil || len(records) != 3 {
+		t.Fatalf("pending records = %d, error = %v, want 3", len(records), err)
+	}
+	// appending a record again is deduplicated by its key
+	if err = store.Append(ctx, records[0]); err != nil {
+		t.Fatalf("failed to append record again: %s", err)
+	}
+
+	var got []string
+	relay := outbox.NewRelay[*testOutboxEntity](store, notifier.PublisherFunc[notifier.ChangeEvent[*testOutboxEntity]](func(ctx context.Context, topic string, c notifier.ChangeEvent[*testOutboxEntity]) error {
+		if !c.ID.Equals(res.GetID()) {
+			t.Errorf("relayed change of %s, want %s", c.ID, res.GetID())
+		}
+		if topic == notifier.Updated && (c.Old.Attr1 != "test" || c.New.Attr1 != "test2") {
+			t.Errorf("relayed update = %+v", c)
+		}
+		got = append(got, topic)
+		return nil
+	}))
+	if published, err := relay.Relay(ctx); err != nil || published != 3 {
+		t.Fatalf("relayed %d records, error = %v, want 3", published, err)
+	}
+	if want := []string{notifier.Added, notifier.Updated, notifier.Deleted}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("relayed %v, want %v", got, want)
+	}
+	if records, err = store.Pending(ctx, 0); err != nil || len(records) != 0 {
+		t.Fatalf("pending records = %d, error = %v, want none", len(records), err)
+	}
+}
@@ -0,0 +1,156 @@
+package mongo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+
+	"github.com/davfer/crudo/entity"
+	"github.com/davfer/crudo/notifier"
+	"github.com/davfer/crudo/outbox"
+)
+
+// OutboxStore is an outbox.Store keeping the records in a collection.
+type OutboxStore struct {
+	Collection *mongo.Collection
+}
+
+func NewOutboxStore(collection *mongo.Collection) *OutboxStore {
+	return &OutboxStore{Collection: collection}
+}
+
+// Start creates the indexes of the outbox, the unique record key deduplicates appends.
+func (s *OutboxStore) Start(ctx context.Context) error {
+	_, err := s.Collection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "key", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "published_at", Value: 1}, {Key: "_id", Value: 1}}},
+	})
+	if err != nil {
+		return fmt.Errorf("could not create outbox indexes: %w", err)
+	}
+
+	return nil
+}
+
+func (s *OutboxStore) Append(ctx context.Context, records ...outbox.Record) error {
+	docs := make([]any, len(records))
+	for i, r := range records {
+		docs[i] = r
+	}
+
+	_, err := s.Collection.InsertMany(ctx, docs, options.InsertMany().SetOrdered(false))
+	if err != nil && !mongo.IsDuplicateKeyError(err) {
+		return fmt.Errorf("error appending outbox records: %w", err)
+	}
+
+	return nil
+}
+
+func (s *OutboxStore) Pending(ctx context.Context, limit int) ([]outbox.Record, error) {
+	findOpts := options.Find().SetSort(bson.D{{Key: "_id", Value: 1}})
+	if limit > 0 {
+		findOpts.SetLimit(int64(limit))
+	}
+
+	cursor, err := s.Collection.Find(ctx, bson.M{"published_at": nil}, findOpts)
+	if err != nil {
+		return nil, fmt.Errorf("error finding pending outbox records: %w", err)
+	}
+
+	var records []outbox.Record
+	if err = cursor.All(ctx, &records); err != nil {
+		return nil, fmt.Errorf("error reading pending outbox records: %w", err)
+	}
+
+	return records, nil
+}
+
+func (s *OutboxStore) Ack(ctx context.Context, keys ...string) error {
+	_, err := s.Collection.UpdateMany(ctx,
+		bson.M{"key": bson.M{"$in": keys}},
+		bson.M{"$set": bson.M{"published_at": time.Now()}},
+	)
+	if err != nil {
+		return fmt.Errorf("error acknowledging outbox records: %w", err)
+	}
+
+	return nil
+}
+
+// OutboxRepository writes every change of the repository and its outbox record in the same transaction, so no change
+// event is lost. Transactions need a replica set or a sharded cluster.
+type OutboxRepository[K entity.Entity] struct {
+	*Repository[K]
+	outbox   *OutboxStore
+	sequence *atomic.Uint64
+}
+
+func NewOutboxRepository[K entity.Entity](repo *Repository[K], outbox *OutboxStore) *OutboxRepository[K] {
+	return &OutboxRepository[K]{Repository: repo, outbox: outbox, sequence: &atomic.Uint64{}}
+}
+
+func (r *OutboxRepository[K]) Create(ctx context.Context, e K) (K, error) {
+	err := r.transaction(ctx, func(ctx context.Context) (err error) {
+		if e, err = r.Repository.Create(ctx, e); err != nil {
+			return err
+		}
+		return r.append(ctx, notifier.Added, *new(K), e)
+	})
+
+	return e, err
+}
+
+func (r *OutboxRepository[K]) Update(ctx context.Context, e K) error {
+	return r.transaction(ctx, func(ctx context.Context) error {
+		old, err := r.Repository.Read(ctx, e.GetID())
+		if err != nil && !errors.Is(err, entity.ErrEntityNotFound) {
+			return err
+		}
+		if err = r.Repository.Update(ctx, e); err != nil {
+			return err
+		}
+		return r.append(ctx, notifier.Updated, old, e)
+	})
+}
+
+func (r *OutboxRepository[K]) Delete(ctx context.Context, e K) error {
+	return r.transaction(ctx, func(ctx context.Context) error {
+		if err := r.Repository.Delete(ctx, e); err != nil {
+			return err
+		}
+		return r.append(ctx, notifier.Deleted, e, *new(K))
+	})
+}
+
+func (r *OutboxRepository[K]) append(ctx context.Context, topic string, before, after K) error {
+	record, err := outbox.NewChangeRecord(ctx, topic, before, after, r.sequence.Add(1))
+	if err != nil {
+		return err
+	}
+
+	return r.outbox.Append(ctx, record)
+}
+
+func (r *OutboxRepository[K]) transaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	session, err := r.Collection.Database().Client().StartSession()
+	if err != nil {
+		return fmt.Errorf("could not start session: %w", err)
+	}
+	defer session.EndSession(ctx)
+
+	_, err = session.WithTransaction(ctx, func(ctx context.Context) (any, error) {
+		return nil, fn(ctx)
+	})
+	if err != nil {
+		r.logger.Error(err, "error in outbox transaction")
+		return fmt.Errorf("error in outbox transaction: %w", err)
+	}
+
+	return nil
+}
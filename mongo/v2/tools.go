@@ -38,3 +38,10 @@ func TryObjectID(i entity.ID) *bson.ObjectID {
 
 	return &id
 }
+
+// ObjectIDStrategy generates ObjectID ids, e.g. for write-behind stores flushing to a mongo repository.
+type ObjectIDStrategy[K entity.Entity] struct{}
+
+func (ObjectIDStrategy[K]) Generate(K) entity.ID {
+	return NewIDFromObjectID(bson.NewObjectID())
+}
@@ -0,0 +1,217 @@
+package mongo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/davfer/archit/patterns/opts"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/bsontype"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/davfer/crudo/entity"
+	"github.com/davfer/crudo/notifier"
+)
+
+var ErrChangeStreamInvalidated = errors.New("change stream invalidated")
+
+// ResumeTokenStore persists the position of a change stream, so a restarted Watch resumes where it stopped.
+type ResumeTokenStore interface {
+	LoadToken(ctx context.Context) (bson.Raw, error)
+	SaveToken(ctx context.Context, token bson.Raw) error
+}
+
+// CollectionTokenStore keeps the resume token of the stream Name as a document of a collection.
+type CollectionTokenStore struct {
+	Collection *mongo.Collection
+	Name       string
+}
+
+func NewCollectionTokenStore(collection *mongo.Collection, name string) *CollectionTokenStore {
+	return &CollectionTokenStore{Collection: collection, Name: name}
+}
+
+func (s *CollectionTokenStore) LoadToken(ctx context.Context) (bson.Raw, error) {
+	var doc struct {
+		Token bson.Raw `bson:"token"`
+	}
+	err := s.Collection.FindOne(ctx, bson.M{"_id": s.Name}).Decode(&doc)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error loading resume token %s: %w", s.Name, err)
+	}
+
+	return doc.Token, nil
+}
+
+func (s *CollectionTokenStore) SaveToken(ctx context.Context, token bson.Raw) error {
+	_, err := s.Collection.UpdateOne(ctx,
+		bson.M{"_id": s.Name},
+		bson.M{"$set": bson.M{"token": token, "updated_at": time.Now()}},
+		options.Update().SetUpsert(true),
+	)
+	if err != nil {
+		return fmt.Errorf("error saving resume token %s: %w", s.Name, err)
+	}
+
+	return nil
+}
+
+type WatchOptions struct {
+	Tokens    ResumeTokenStore
+	PreImages bool
+	StartAt   *primitive.Timestamp
+}
+
+// WithResumeTokens persists the stream position after every published change.
+func WithResumeTokens(tokens ResumeTokenStore) opts.Opt[WatchOptions] {
+	return func(w WatchOptions) WatchOptions {
+		w.Tokens = tokens
+		return w
+	}
+}
+
+// WithPreImages fills the old side of updates and deletes, the collection must have pre-images enabled. Otherwise
+// deletes only carry the id of the entity.
+func WithPreImages() opts.Opt[WatchOptions] {
+	return func(w WatchOptions) WatchOptions {
+		w.PreImages = true
+		return w
+	}
+}
+
+// WithStartAtOperationTime watches the changes made from t on, e.g. the operation time of a session taken before
+// writing, so they are watched even if the stream opens after them. A resume token found takes precedence.
+func WithStartAtOperationTime(t primitive.Timestamp) opts.Opt[WatchOptions] {
+	return func(w WatchOptions) WatchOptions {
+		w.StartAt = &t
+		return w
+	}
+}
+
+type changeStreamEvent struct {
+	OperationType            string              `bson:"operationType"`
+	ClusterTime              primitive.Timestamp `bson:"clusterTime"`
+	DocumentKey              bson.RawValue       `bson:"documentKey"`
+	FullDocument             bson.RawValue       `bson:"fullDocument"`
+	FullDocumentBeforeChange bson.RawValue       `bson:"fullDocumentBeforeChange"`
+}
+
+// Watch publishes the changes of the collection made by anyone until ctx is done, e.g. to a store.ProxyStore through
+// its ApplyChange. A change is published at least once: Watch stops on the first publishing error and a restart with
+// the same ResumeTokenStore publishes it again. Change streams need a replica set or a sharded cluster.
+func (r *Repository[K]) Watch(ctx context.Context, publisher notifier.Publisher[notifier.ChangeEvent[K]], o ...opts.Opt[WatchOptions]) error {
+	w := opts.New[WatchOptions](o...)
+
+	csOpts := options.ChangeStream().SetFullDocument(options.UpdateLookup)
+	if w.PreImages {
+		csOpts.SetFullDocumentBeforeChange(options.WhenAvailable)
+	}
+	var token bson.Raw
+	if w.Tokens != nil {
+		var err error
+		if token, err = w.Tokens.LoadToken(ctx); err != nil {
+			return err
+		}
+	}
+	if token != nil {
+		csOpts.SetResumeAfter(token)
+	} else if w.StartAt != nil {
+		csOpts.SetStartAtOperationTime(w.StartAt)
+	}
+
+	cs, err := r.Collection.Watch(ctx, mongo.Pipeline{}, csOpts)
+	if err != nil {
+		r.logger.Error(err, "error opening change stream")
+		return fmt.Errorf("error opening change stream: %w", err)
+	}
+	defer cs.Close(context.WithoutCancel(ctx))
+
+	r.logger.Info("watching collection", "collection", r.Collection.Name())
+	var sequence uint64
+	for cs.Next(ctx) {
+		var ev changeStreamEvent
+		if err = cs.Decode(&ev); err != nil {
+			return fmt.Errorf("error decoding change: %w", err)
+		}
+		if ev.OperationType == "invalidate" {
+			return ErrChangeStreamInvalidated
+		}
+
+		c, ok, err := decodeChange[K](ctx, ev)
+		if err != nil {
+			r.logger.Error(err, "error decoding change", "operation", ev.OperationType)
+			return err
+		}
+		if ok {
+			sequence++
+			c.Sequence = sequence
+			if err = publisher.Notify(ctx, c.Type, c); err != nil {
+				return fmt.Errorf("could not publish change of entity %s: %w", c.ID, err)
+			}
+		}
+
+		if w.Tokens != nil {
+			if err = w.Tokens.SaveToken(ctx, cs.ResumeToken()); err != nil {
+				return err
+			}
+		}
+	}
+
+	if err = cs.Err(); err != nil && ctx.Err() == nil {
+		r.logger.Error(err, "error watching collection")
+		return fmt.Errorf("error watching collection: %w", err)
+	}
+
+	return ctx.Err()
+}
+
+// decodeChange turns a change stream event into a change event, false for the events not changing a document or
+// whose document is gone.
+func decodeChange[K entity.Entity](ctx context.Context, ev changeStreamEvent) (notifier.ChangeEvent[K], bool, error) {
+	var before, after K
+	var topic string
+	var err error
+	switch ev.OperationType {
+	case "insert":
+		topic = notifier.Added
+	case "update", "replace":
+		topic = notifier.Updated
+	case "delete":
+		topic = notifier.Deleted
+	default:
+		return notifier.ChangeEvent[K]{}, false, nil
+	}
+
+	if ev.FullDocumentBeforeChange.Type == bsontype.EmbeddedDocument {
+		if err = ev.FullDocumentBeforeChange.Unmarshal(&before); err != nil {
+			return notifier.ChangeEvent[K]{}, false, fmt.Errorf("error decoding previous document: %w", err)
+		}
+	} else if topic == notifier.Deleted {
+		// the key is all that is left of a deleted document
+		if err = ev.DocumentKey.Unmarshal(&before); err != nil {
+			return notifier.ChangeEvent[K]{}, false, fmt.Errorf("error decoding document key: %w", err)
+		}
+	}
+
+	if topic != notifier.Deleted {
+		if ev.FullDocument.Type != bsontype.EmbeddedDocument {
+			// deleted before the update was looked up, its delete follows
+			return notifier.ChangeEvent[K]{}, false, nil
+		}
+		if err = ev.FullDocument.Unmarshal(&after); err != nil {
+			return notifier.ChangeEvent[K]{}, false, fmt.Errorf("error decoding document: %w", err)
+		}
+	}
+
+	c := notifier.NewChangeEvent(ctx, topic, before, after, 0)
+	c.Timestamp = time.Unix(int64(ev.ClusterTime.T), 0)
+
+	return c, true, nil
+}
@@ -0,0 +1,107 @@
+package mongo_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/davfer/crudo/mongo"
+	"github.com/davfer/crudo/notifier"
+	"github.com/testcontainers/testcontainers-go"
+	"github.com/testcontainers/testcontainers-go/modules/mongodb"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	mongo2 "go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func TestRepository_Watch(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// change streams need a replica set
+	mongodbContainer, err := mongodb.Run(ctx, "mongo:latest", mongodb.WithReplicaSet("rs0"))
+	defer func() {
+		if err := testcontainers.TerminateContainer(mongodbContainer); err != nil {
+			t.Fatalf("failed to terminate container: %s", err)
+		}
+	}()
+	if err != nil {
+		t.Fatalf("failed to start container: %s", err)
+	}
+	uri, err := mongodbContainer.ConnectionString(ctx)
+	if err != nil {
+		t.Fatalf("failed to get connection string: %s", err)
+	}
+
+	client, err := mongo2.Connect(ctx, options.Client().ApplyURI(uri))
+	if err != nil {
+		t.Fatalf("failed to connect to mongo: %s", err)
+	}
+	defer func() {
+		if err := client.Disconnect(ctx); err != nil {
+			t.Fatalf("failed to disconnect from mongo: %s", err)
+		}
+	}()
+	db := client.Database("test")
+	repo := mongo.NewMongoRepository[*testOutboxEntity](db.Collection("test"))
+	tokens := mongo.NewCollectionTokenStore(db.Collection("tokens"), "test")
+
+	// the stream starts at an operation time taken before writing, whenever it opens
+	sess, err := client.StartSession()
+	if err != nil {
+		t.Fatalf("failed to start session: %s", err)
+	}
+	defer sess.EndSession(ctx)
+	if err = db.RunCommand(mongo2.NewSessionContext(ctx, sess), bson.D{{Key: "ping", Value: 1}}).Err(); err != nil {
+		t.Fatalf("failed to ping: %s", err)
+	}
+	start := sess.OperationTime()
+	if start == nil {
+		t.Fatalf("no operation time to start watching at")
+	}
+
+	changes := make(chan notifier.ChangeEvent[*testOutboxEntity], 10)
+	watchCtx, stop := context.WithCancel(ctx)
+	done := make(chan error, 1)
+	go func() {
+		done <- repo.Watch(watchCtx, notifier.PublisherFunc[notifier.ChangeEvent[*testOutboxEntity]](func(ctx context.Context, topic string, c notifier.ChangeEvent[*testOutboxEntity]) error {
+			changes <- c
+			return nil
+		}), mongo.WithResumeTokens(tokens), mongo.WithStartAtOperationTime(*start))
+	}()
+
+	res, err := repo.Create(ctx, &testOutboxEntity{ID: primitive.NewObjectID(), Attr1: "test"})
+	if err != nil {
+		t.Fatalf("failed to create entity: %s", err)
+	}
+	if err = repo.Update(ctx, &testOutboxEntity{ID: res.ID, Attr1: "test2"}); err != nil {
+		t.Fatalf("failed to update entity: %s", err)
+	}
+	if err = repo.Delete(ctx, res); err != nil {
+		t.Fatalf("failed to delete entity: %s", err)
+	}
+
+	for _, want := range []string{notifier.Added, notifier.Updated, notifier.Deleted} {
+		select {
+		case c := <-changes:
+			if c.Type != want || !c.ID.Equals(res.GetID()) {
+				t.Fatalf("watched change %s of %s, want %s of %s", c.Type, c.ID, want, res.GetID())
+			}
+			if want == notifier.Updated && c.New.Attr1 != "test2" {
+				t.Fatalf("watched update attr1 = %s, want test2", c.New.Attr1)
+			}
+		case <-time.After(10 * time.Second):
+			t.Fatalf("change %s not watched", want)
+		}
+	}
+
+	stop()
+	if err = <-done; !errors.Is(err, context.Canceled) {
+		t.Fatalf("watch error = %v, want %v", err, context.Canceled)
+	}
+	if token, err := tokens.LoadToken(ctx); err != nil || token == nil {
+		t.Fatalf("resume token not saved: %v", err)
+	}
+}
@@ -0,0 +1,84 @@
+package notifier
+
+import (
+	"context"
+	"reflect"
+	"time"
+
+	"github.com/davfer/crudo/entity"
+)
+
+// Topics of the changes of entities, as notified by stores, watches and outboxes.
+const (
+	Added   = "added"
+	Updated = "updated"
+	Deleted = "deleted"
+)
+
+// ChangeEvent carries both sides of a change, Old is empty for additions and New is empty for removals.
+type ChangeEvent[K any] struct {
+	Type          string
+	Old           K
+	New           K
+	ID            entity.ID // ID of the changed entity
+	Timestamp     time.Time
+	Actor         string // Actor who made the change, see WithActor
+	CorrelationID string // CorrelationID of the operation that made the change, see WithCorrelationID
+	Sequence      uint64 // Sequence increases with every event of the same emitter
+}
+
+// NewChangeEvent describes a change of an entity, taking the actor and the correlation id from ctx.
+func NewChangeEvent[K entity.Entity](ctx context.Context, topic string, before, after K, sequence uint64) ChangeEvent[K] {
+	id, ok := idOf(after)
+	if !ok {
+		id, _ = idOf(before)
+	}
+	actor, _ := ActorFromContext(ctx)
+	correlationID, _ := CorrelationIDFromContext(ctx)
+
+	return ChangeEvent[K]{
+		Type:          topic,
+		Old:           before,
+		New:           after,
+		ID:            id,
+		Timestamp:     time.Now(),
+		Actor:         actor,
+		CorrelationID: correlationID,
+		Sequence:      sequence,
+	}
+}
+
+// idOf returns the id of e, false if e is empty, e.g. the missing side of a change.
+func idOf[K entity.Entity](e K) (entity.ID, bool) {
+	v := reflect.ValueOf(e)
+	if !v.IsValid() || (v.Kind() == reflect.Pointer && v.IsNil()) {
+		return "", false
+	}
+
+	id := e.GetID()
+	return id, !id.IsEmpty()
+}
+
+type actorKey struct{}
+
+type correlationIDKey struct{}
+
+// WithActor returns a context whose changes are made by actor.
+func WithActor(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, actorKey{}, actor)
+}
+
+func ActorFromContext(ctx context.Context) (string, bool) {
+	actor, ok := ctx.Value(actorKey{}).(string)
+	return actor, ok
+}
+
+// WithCorrelationID returns a context whose changes are correlated by id.
+func WithCorrelationID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, correlationIDKey{}, id)
+}
+
+func CorrelationIDFromContext(ctx context.Context) (string, bool) {
+	id, ok := ctx.Value(correlationIDKey{}).(string)
+	return id, ok
+}
@@ -0,0 +1,268 @@
+package notifier
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"sync"
+	"sync/atomic"
+
+	"github.com/davfer/archit/patterns/opts"
+	"github.com/go-logr/logr"
+)
+
+var (
+	ErrQueueFull        = errors.New("dispatch queue full")
+	ErrDispatcherClosed = errors.New("dispatcher closed")
+)
+
+type Backpressure string
+
+const (
+	BackpressureBlock Backpressure = "block" // BackpressureBlock waits for room in the queue or for the context to be done
+	BackpressureDrop  Backpressure = "drop"  // BackpressureDrop discards the event
+	BackpressureError Backpressure = "error" // BackpressureError discards the event returning ErrQueueFull
+)
+
+// Publisher is what a Dispatcher delivers the events to, e.g. a TopicNotifier.
+type Publisher[K any] interface {
+	Notify(ctx context.Context, topic string, event K) error
+}
+
+// PublisherFunc adapts a function to a Publisher.
+type PublisherFunc[K any] func(ctx context.Context, topic string, event K) error
+
+func (f PublisherFunc[K]) Notify(ctx context.Context, topic string, event K) error {
+	return f(ctx, topic, event)
+}
+
+type dispatch[K any] struct {
+	ctx   context.Context
+	topic string
+	event K
+}
+
+// topicQueues are the buffered queues of a topic, one per worker.
+type topicQueues[K any] struct {
+	workers []chan dispatch[K]
+	next    atomic.Uint32
+}
+
+// Dispatcher publishes events asynchronously. Each topic has its own pool of Workers, each one with a buffered queue
+// of QueueSize/Workers events, so a busy topic does not hold up the others. Events with the same Key are handled in
+// order by the same worker, events without one are spread round-robin. Topics grouped by Queue share their queues,
+// keeping the order of the events with the same Key across them.
+type Dispatcher[K any] struct {
+	Workers      int
+	QueueSize    int
+	Backpressure Backpressure
+	Key          func(K) string
+	Queue        func(topic string) string // Queue names the queues of a topic, the topic itself by default
+	publisher    Publisher[K]
+	logger       logr.Logger
+	lock         *sync.RWMutex
+	queues       map[string]*topicQueues[K]
+	closed       bool
+	done         chan struct{} // done is closed by Close, releasing the blocked senders
+	senders      *sync.WaitGroup
+	wg           *sync.WaitGroup
+}
+
+func NewDispatcher[K any](publisher Publisher[K], o ...opts.Opt[Dispatcher[K]]) *Dispatcher[K] {
+	d := opts.New[Dispatcher[K]](o...)
+
+	d.publisher = publisher
+	if d.Workers <= 0 {
+		d.Workers = 1
+	}
+	if d.QueueSize <= 0 {
+		d.QueueSize = 100
+	}
+	if d.Backpressure == "" {
+		d.Backpressure = BackpressureBlock
+	}
+	if d.Queue == nil {
+		d.Queue = func(topic string) string { return topic }
+	}
+	if d.logger.GetSink() == nil {
+		d.logger = logr.Discard()
+	}
+	d.lock = &sync.RWMutex{}
+	d.queues = map[string]*topicQueues[K]{}
+	d.done = make(chan struct{})
+	d.senders = &sync.WaitGroup{}
+	d.wg = &sync.WaitGroup{}
+
+	return &d
+}
+
+func WithWorkers[K any](workers int) opts.Opt[Dispatcher[K]] {
+	return func(d Dispatcher[K]) Dispatcher[K] {
+		d.Workers = workers
+		return d
+	}
+}
+
+func WithQueueSize[K any](size int) opts.Opt[Dispatcher[K]] {
+	return func(d Dispatcher[K]) Dispatcher[K] {
+		d.QueueSize = size
+		return d
+	}
+}
+
+func WithBackpressure[K any](b Backpressure) opts.Opt[Dispatcher[K]] {
+	return func(d Dispatcher[K]) Dispatcher[K] {
+		d.Backpressure = b
+		return d
+	}
+}
+
+// WithKey orders the events sharing the same key, e.g. the entity ID.
+func WithKey[K any](key func(K) string) opts.Opt[Dispatcher[K]] {
+	return func(d Dispatcher[K]) Dispatcher[K] {
+		d.Key = key
+		return d
+	}
+}
+
+// WithQueueOf shares the queues of the topics with the same queue name, e.g. to keep the order of the events of an
+// entity across its added, updated and deleted topics.
+func WithQueueOf[K any](queue func(topic string) string) opts.Opt[Dispatcher[K]] {
+	return func(d Dispatcher[K]) Dispatcher[K] {
+		d.Queue = queue
+		return d
+	}
+}
+
+func WithDispatcherLogger[K any](logger logr.Logger) opts.Opt[Dispatcher[K]] {
+	return func(d Dispatcher[K]) Dispatcher[K] {
+		d.logger = logger
+		return d
+	}
+}
+
+// Notify queues the event for its topic. Observer errors are logged, as the caller is gone by the time they run.
+func (d *Dispatcher[K]) Notify(ctx context.Context, topic string, event K) error {
+	queues, err := d.sender(topic)
+	if err != nil {
+		return err
+	}
+	defer d.senders.Done()
+
+	q := queues.workers[d.worker(queues, event)]
+	// the handling outlives the caller
+	msg := dispatch[K]{ctx: context.WithoutCancel(ctx), topic: topic, event: event}
+	switch d.Backpressure {
+	case BackpressureDrop:
+		select {
+		case q <- msg:
+		default:
+			d.logger.Info("dropping event, dispatch queue full", "topic", topic)
+		}
+	case BackpressureError:
+		select {
+		case q <- msg:
+		default:
+			return fmt.Errorf("could not dispatch event to topic %s: %w", topic, ErrQueueFull)
+		}
+	default:
+		select {
+		case q <- msg:
+		case <-ctx.Done():
+			return fmt.Errorf("could not dispatch event to topic %s: %w", topic, ctx.Err())
+		case <-d.done:
+			return fmt.Errorf("could not dispatch event to topic %s: %w", topic, ErrDispatcherClosed)
+		}
+	}
+
+	return nil
+}
+
+// Close stops accepting events and waits for the queued ones to be handled, or for ctx to be done.
+func (d *Dispatcher[K]) Close(ctx context.Context) error {
+	d.lock.Lock()
+	closing := !d.closed
+	d.closed = true
+	d.lock.Unlock()
+
+	drained := make(chan struct{})
+	go func() {
+		if closing {
+			close(d.done)
+			// no sender is left once they are released, and no queue is created once closed
+			d.senders.Wait()
+			for _, queues := range d.queues {
+				for _, q := range queues.workers {
+					close(q)
+				}
+			}
+		}
+		d.wg.Wait()
+		close(drained)
+	}()
+
+	select {
+	case <-drained:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("could not drain dispatcher: %w", ctx.Err())
+	}
+}
+
+// sender registers a sender to the queues of the topic, starting its workers the first time. The lock is not held
+// while blocking on a full queue, Close waits for the senders instead.
+func (d *Dispatcher[K]) sender(topic string) (*topicQueues[K], error) {
+	name := d.Queue(topic)
+
+	d.lock.RLock()
+	queues, ok := d.queues[name]
+	if ok && !d.closed {
+		d.senders.Add(1)
+		d.lock.RUnlock()
+		return queues, nil
+	}
+	d.lock.RUnlock()
+
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	if d.closed {
+		return nil, ErrDispatcherClosed
+	}
+	if queues, ok = d.queues[name]; !ok {
+		queues = &topicQueues[K]{workers: make([]chan dispatch[K], d.Workers)}
+		for i := range queues.workers {
+			queues.workers[i] = make(chan dispatch[K], max(d.QueueSize/d.Workers, 1))
+			d.wg.Add(1)
+			go d.work(queues.workers[i])
+		}
+		d.queues[name] = queues
+	}
+	d.senders.Add(1)
+
+	return queues, nil
+}
+
+func (d *Dispatcher[K]) worker(queues *topicQueues[K], event K) int {
+	if d.Workers == 1 {
+		return 0
+	}
+	if d.Key == nil {
+		return int((queues.next.Add(1) - 1) % uint32(d.Workers))
+	}
+
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(d.Key(event)))
+	return int(h.Sum32() % uint32(d.Workers))
+}
+
+func (d *Dispatcher[K]) work(q chan dispatch[K]) {
+	defer d.wg.Done()
+
+	for msg := range q {
+		if err := d.publisher.Notify(msg.ctx, msg.topic, msg.event); err != nil {
+			d.logger.Error(err, "error handling dispatched event", "topic", msg.topic)
+		}
+	}
+}
@@ -0,0 +1,217 @@
+package notifier_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/davfer/crudo/notifier"
+)
+
+type testEvent struct {
+	Key string
+	Seq int
+}
+
+func TestDispatcher_Order(t *testing.T) {
+	ctx := context.TODO()
+	n := notifier.NewTopicCallbackNotifier[testEvent]([]string{"updated"})
+
+	var lock sync.Mutex
+	got := map[string][]int{}
+	_, _ = n.Attach("updated", func(ctx context.Context, e testEvent) error {
+		lock.Lock()
+		defer lock.Unlock()
+		got[e.Key] = append(got[e.Key], e.Seq)
+		return nil
+	})
+
+	d := notifier.NewDispatcher[testEvent](n,
+		notifier.WithWorkers[testEvent](4),
+		notifier.WithKey(func(e testEvent) string { return e.Key }),
+	)
+	for seq := range 50 {
+		for k := range 5 {
+			if err := d.Notify(ctx, "updated", testEvent{Key: fmt.Sprint(k), Seq: seq}); err != nil {
+				t.Fatalf("Notify() error = %v", err)
+			}
+		}
+	}
+	if err := d.Close(ctx); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	want := make([]int, 50)
+	for i := range want {
+		want[i] = i
+	}
+	for k := range 5 {
+		if !reflect.DeepEqual(got[fmt.Sprint(k)], want) {
+			t.Errorf("key %d handled out of order: %v", k, got[fmt.Sprint(k)])
+		}
+	}
+	if err := d.Notify(ctx, "updated", testEvent{}); !errors.Is(err, notifier.ErrDispatcherClosed) {
+		t.Errorf("Notify() after Close error = %v, want %v", err, notifier.ErrDispatcherClosed)
+	}
+}
+
+func TestDispatcher_Backpressure(t *testing.T) {
+	tests := []struct {
+		name         string
+		backpressure notifier.Backpressure
+		wantErr      error
+		wantHandled  int
+	}{
+		{name: "Test drop", backpressure: notifier.BackpressureDrop, wantHandled: 2},
+		{name: "Test error", backpressure: notifier.BackpressureError, wantErr: notifier.ErrQueueFull, wantHandled: 2},
+		{name: "Test block", backpressure: notifier.BackpressureBlock, wantErr: context.DeadlineExceeded, wantHandled: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n := notifier.NewTopicCallbackNotifier[testEvent]([]string{"updated"})
+			release := make(chan struct{})
+			var lock sync.Mutex
+			handled := 0
+			_, _ = n.Attach("updated", func(ctx context.Context, e testEvent) error {
+				<-release
+				lock.Lock()
+				defer lock.Unlock()
+				handled++
+				return nil
+			})
+			d := notifier.NewDispatcher[testEvent](n,
+				notifier.WithQueueSize[testEvent](1),
+				notifier.WithBackpressure[testEvent](tt.backpressure),
+			)
+
+			// the first event keeps the worker busy, the second one fills the queue
+			ctx, cancel := context.WithTimeout(context.TODO(), 50*time.Millisecond)
+			defer cancel()
+			_ = d.Notify(ctx, "updated", testEvent{Seq: 1})
+			time.Sleep(10 * time.Millisecond)
+			_ = d.Notify(ctx, "updated", testEvent{Seq: 2})
+			err := d.Notify(ctx, "updated", testEvent{Seq: 3})
+			if (tt.wantErr == nil && err != nil) || (tt.wantErr != nil && !errors.Is(err, tt.wantErr)) {
+				t.Errorf("Notify() error = %v, want %v", err, tt.wantErr)
+			}
+
+			close(release)
+			if err = d.Close(context.TODO()); err != nil {
+				t.Fatalf("Close() error = %v", err)
+			}
+			if handled != tt.wantHandled {
+				t.Errorf("handled = %d, want %d", handled, tt.wantHandled)
+			}
+		})
+	}
+}
+
+func TestDispatcher_OrderAcrossTopics(t *testing.T) {
+	ctx := context.TODO()
+	n := notifier.NewTopicCallbackNotifier[testEvent]([]string{"added", "deleted"})
+
+	var lock sync.Mutex
+	var got []string
+	record := func(ctx context.Context, e testEvent) error {
+		topic, _ := notifier.TopicFromContext(ctx)
+		lock.Lock()
+		defer lock.Unlock()
+		got = append(got, topic)
+		return nil
+	}
+	_, _ = n.Attach("added", func(ctx context.Context, e testEvent) error {
+		time.Sleep(20 * time.Millisecond)
+		return record(ctx, e)
+	})
+	_, _ = n.Attach("deleted", record)
+
+	// the topics share their queues
+	d := notifier.NewDispatcher[testEvent](n,
+		notifier.WithWorkers[testEvent](4),
+		notifier.WithKey(func(e testEvent) string { return e.Key }),
+		notifier.WithQueueOf[testEvent](func(string) string { return "entities" }),
+	)
+	_ = d.Notify(ctx, "added", testEvent{Key: "1"})
+	_ = d.Notify(ctx, "deleted", testEvent{Key: "1"})
+	if err := d.Close(ctx); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	if !reflect.DeepEqual(got, []string{"added", "deleted"}) {
+		t.Errorf("handled = %v, want [added deleted]", got)
+	}
+}
+
+func TestDispatcher_TopicQueues(t *testing.T) {
+	n := notifier.NewTopicCallbackNotifier[testEvent]([]string{"added", "updated"})
+	release := make(chan struct{})
+	_, _ = n.Attach("added", func(ctx context.Context, e testEvent) error {
+		<-release
+		return nil
+	})
+	updated := make(chan struct{}, 1)
+	_, _ = n.Attach("updated", func(ctx context.Context, e testEvent) error {
+		updated <- struct{}{}
+		return nil
+	})
+	d := notifier.NewDispatcher[testEvent](n, notifier.WithQueueSize[testEvent](1))
+
+	// the added worker is stuck and its queue is full
+	_ = d.Notify(context.TODO(), "added", testEvent{Seq: 1})
+	time.Sleep(10 * time.Millisecond)
+	_ = d.Notify(context.TODO(), "added", testEvent{Seq: 2})
+
+	ctx, cancel := context.WithTimeout(context.TODO(), time.Second)
+	defer cancel()
+	if err := d.Notify(ctx, "updated", testEvent{Seq: 3}); err != nil {
+		t.Fatalf("Notify() error = %v", err)
+	}
+	select {
+	case <-updated:
+	case <-ctx.Done():
+		t.Errorf("updated event held up by the added topic")
+	}
+
+	close(release)
+	if err := d.Close(context.TODO()); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+}
+
+func TestDispatcher_CloseDeadline(t *testing.T) {
+	n := notifier.NewTopicCallbackNotifier[testEvent]([]string{"updated"})
+	release := make(chan struct{})
+	defer close(release)
+	_, _ = n.Attach("updated", func(ctx context.Context, e testEvent) error {
+		<-release
+		return nil
+	})
+	d := notifier.NewDispatcher[testEvent](n, notifier.WithQueueSize[testEvent](1))
+
+	// the worker is stuck, the queue is full and a sender blocks on it
+	_ = d.Notify(context.TODO(), "updated", testEvent{Seq: 1})
+	time.Sleep(10 * time.Millisecond)
+	_ = d.Notify(context.TODO(), "updated", testEvent{Seq: 2})
+	blocked := make(chan error, 1)
+	go func() {
+		blocked <- d.Notify(context.TODO(), "updated", testEvent{Seq: 3})
+	}()
+	time.Sleep(10 * time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.TODO(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if err := d.Close(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Close() error = %v, want %v", err, context.DeadlineExceeded)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Close() took %v past its deadline", elapsed)
+	}
+	if err := <-blocked; !errors.Is(err, notifier.ErrDispatcherClosed) {
+		t.Errorf("blocked Notify() error = %v, want %v", err, notifier.ErrDispatcherClosed)
+	}
+}
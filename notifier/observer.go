@@ -2,7 +2,10 @@ package notifier
 
 import (
 	"context"
-	"fmt"
+	"reflect"
+	"sync"
+
+	"github.com/davfer/archit/patterns/opts"
 )
 
 type Observer[K any] interface {
@@ -11,28 +14,49 @@ type Observer[K any] interface {
 
 type ObserverCallback[K any] func(context.Context, K) error
 
+type subscribed[K any] struct {
+	id       uint64
+	observer Observer[K]
+}
+
+// Notifier delivers events to its observers, it is safe for concurrent use and observers may unsubscribe while
+// handling an event.
 type Notifier[K any] struct {
-	observers []Observer[K]
+	observers []subscribed[K]
+	policy    Policy
+	lock      *sync.RWMutex
+	lastID    uint64
 }
 
-func NewNotifier[K any]() *Notifier[K] {
+func NewNotifier[K any](o ...opts.Opt[Policy]) *Notifier[K] {
 	n := &Notifier[K]{
-		observers: []Observer[K]{},
+		observers: []subscribed[K]{},
+		policy:    newPolicy(o...),
+		lock:      &sync.RWMutex{},
 	}
 
 	return n
 }
 
-func (n *Notifier[K]) Attach(observer Observer[K]) error {
-	n.observers = append(n.observers, observer)
+func (n *Notifier[K]) Attach(observer Observer[K]) (*Subscription, error) {
+	n.lock.Lock()
+	defer n.lock.Unlock()
 
-	return nil
+	n.lastID++
+	id := n.lastID
+	n.observers = append(n.observers, subscribed[K]{id: id, observer: observer})
+
+	return newSubscription(func() { n.detach(id) }), nil
 }
 
+// Detach removes the first attachment of the observer, prefer the Subscription returned by Attach.
 func (n *Notifier[K]) Detach(observer Observer[K]) error {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+
 	for i, h := range n.observers {
-		if h == observer {
-			n.observers = append(n.observers[:i], n.observers[i+1:]...)
+		if same(h.observer, observer) {
+			n.observers = append(n.observers[:i:i], n.observers[i+1:]...)
 			break
 		}
 	}
@@ -40,14 +64,36 @@ func (n *Notifier[K]) Detach(observer Observer[K]) error {
 	return nil
 }
 
+// Notify delivers the event to every observer, the returned error joins the ObserverError of each failing one.
 func (n *Notifier[K]) Notify(ctx context.Context, event K) error {
-	var err error
-	for _, d := range n.observers {
-		err = d.Handle(ctx, event)
-		if err != nil {
-			err = fmt.Errorf("failed to notify observer: %w", err)
+	n.lock.RLock()
+	observers := make([]Observer[K], len(n.observers))
+	for i, o := range n.observers {
+		observers[i] = o.observer
+	}
+	n.lock.RUnlock()
+
+	return notifyAll(ctx, n.policy, "", observers, event)
+}
+
+func (n *Notifier[K]) detach(id uint64) {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+
+	for i, h := range n.observers {
+		if h.id == id {
+			n.observers = append(n.observers[:i:i], n.observers[i+1:]...)
+			break
 		}
 	}
+}
+
+// same compares two observers without panicking on uncomparable ones, e.g. func based observers.
+func same(a, b any) bool {
+	ta, tb := reflect.TypeOf(a), reflect.TypeOf(b)
+	if ta != tb || !ta.Comparable() {
+		return false
+	}
 
-	return err
+	return a == b
 }
@@ -0,0 +1,142 @@
+package notifier
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"runtime"
+	"time"
+
+	"github.com/davfer/archit/patterns/opts"
+)
+
+var ErrObserverPanic = errors.New("observer panicked")
+
+type FailurePolicy string
+
+const (
+	FailureContinue FailurePolicy = "continue" // FailureContinue notifies every observer and joins their errors
+	FailureStop     FailurePolicy = "stop"     // FailureStop skips the remaining observers after the first error
+)
+
+// ObserverError is the failure of a single observer, the errors of every failing observer are joined.
+type ObserverError struct {
+	Index    int    // Index of the observer in attach order
+	Observer string // Observer name, see Named
+	Topic    string
+	Err      error
+}
+
+func (e *ObserverError) Error() string {
+	if e.Topic == "" {
+		return fmt.Sprintf("observer %d (%s) failed: %v", e.Index, e.Observer, e.Err)
+	}
+
+	return fmt.Sprintf("observer %d (%s) failed on topic %s: %v", e.Index, e.Observer, e.Topic, e.Err)
+}
+
+func (e *ObserverError) Unwrap() error {
+	return e.Err
+}
+
+// Named observers are identified by their name in errors, others by their type or function name.
+type Named interface {
+	Name() string
+}
+
+// Policy sets how notifiers deal with failing observers.
+type Policy struct {
+	OnError FailurePolicy
+	Retries int           // Retries of a failing observer before giving up
+	Backoff time.Duration // Backoff before the first retry, doubled on every retry
+	Recover bool          // Recover turns observer panics into errors wrapping ErrObserverPanic
+}
+
+func newPolicy(o ...opts.Opt[Policy]) Policy {
+	p := opts.New[Policy](o...)
+	if p.OnError == "" {
+		p.OnError = FailureContinue
+	}
+
+	return p
+}
+
+func WithFailurePolicy(f FailurePolicy) opts.Opt[Policy] {
+	return func(p Policy) Policy {
+		p.OnError = f
+		return p
+	}
+}
+
+func WithRetry(retries int, backoff time.Duration) opts.Opt[Policy] {
+	return func(p Policy) Policy {
+		p.Retries = retries
+		p.Backoff = backoff
+		return p
+	}
+}
+
+func WithPanicRecovery() opts.Opt[Policy] {
+	return func(p Policy) Policy {
+		p.Recover = true
+		return p
+	}
+}
+
+// notifyAll delivers the event to the observers following the policy.
+func notifyAll[K any](ctx context.Context, p Policy, topic string, observers []Observer[K], event K) error {
+	var errs []error
+	for i, o := range observers {
+		if err := handle(ctx, p, o, event); err != nil {
+			errs = append(errs, &ObserverError{Index: i, Observer: observerName(o), Topic: topic, Err: err})
+			if p.OnError == FailureStop {
+				break
+			}
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// handle delivers the event to a single observer, retrying it with an exponential backoff.
+func handle[K any](ctx context.Context, p Policy, o Observer[K], event K) (err error) {
+	backoff := p.Backoff
+	for attempt := 0; ; attempt++ {
+		if err = call(ctx, p, o, event); err == nil || attempt >= p.Retries {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return errors.Join(err, ctx.Err())
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+func call[K any](ctx context.Context, p Policy, o Observer[K], event K) (err error) {
+	if p.Recover {
+		defer func() {
+			if r := recover(); r != nil {
+				err = fmt.Errorf("%w: %v", ErrObserverPanic, r)
+			}
+		}()
+	}
+
+	return o.Handle(ctx, event)
+}
+
+func observerName(o any) string {
+	switch v := o.(type) {
+	case Named:
+		return v.Name()
+	case interface{ callback() any }:
+		if f := runtime.FuncForPC(reflect.ValueOf(v.callback()).Pointer()); f != nil {
+			return f.Name()
+		}
+	}
+
+	return fmt.Sprintf("%T", o)
+}
@@ -0,0 +1,89 @@
+package notifier_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/davfer/archit/patterns/opts"
+	"github.com/davfer/crudo/notifier"
+)
+
+type namedObserver struct {
+	name string
+	err  error
+	runs int
+}
+
+func (o *namedObserver) Name() string {
+	return o.name
+}
+
+func (o *namedObserver) Handle(context.Context, testEvent) error {
+	o.runs++
+	if o.runs > 1 && errors.Is(o.err, errFlaky) {
+		return nil
+	}
+	return o.err
+}
+
+var (
+	errFirst = errors.New("first")
+	errLast  = errors.New("last")
+	errFlaky = errors.New("flaky")
+)
+
+func TestNotifier_Policy(t *testing.T) {
+	tests := []struct {
+		name     string
+		policy   []opts.Opt[notifier.Policy]
+		err      error
+		wantErrs []error
+		wantRuns int
+	}{
+		{name: "Test continue joins errors", err: errFirst, wantErrs: []error{errFirst, errLast}, wantRuns: 1},
+		{name: "Test stop on first error", policy: []opts.Opt[notifier.Policy]{notifier.WithFailurePolicy(notifier.FailureStop)}, err: errFirst, wantErrs: []error{errFirst}, wantRuns: 0},
+		{name: "Test retry", policy: []opts.Opt[notifier.Policy]{notifier.WithRetry(2, time.Millisecond)}, err: errFlaky, wantErrs: []error{errLast}, wantRuns: 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			first := &namedObserver{name: "first", err: tt.err}
+			last := &namedObserver{name: "last", err: errLast}
+			n := notifier.NewNotifier[testEvent](tt.policy...)
+			_, _ = n.Attach(first)
+			_, _ = n.Attach(last)
+
+			err := n.Notify(context.TODO(), testEvent{})
+			for _, want := range tt.wantErrs {
+				if !errors.Is(err, want) {
+					t.Errorf("Notify() error = %v, want %v", err, want)
+				}
+			}
+			if last.runs != tt.wantRuns {
+				t.Errorf("last observer runs = %d, want %d", last.runs, tt.wantRuns)
+			}
+
+			var oe *notifier.ObserverError
+			if !errors.As(err, &oe) || !errors.Is(oe, tt.wantErrs[0]) || oe.Observer != oe.Err.Error() {
+				t.Errorf("Notify() error = %v, want the identity of the failing observer", err)
+			}
+		})
+	}
+}
+
+func TestTopicCallbackNotifier_PanicRecovery(t *testing.T) {
+	n := notifier.NewTopicCallbackNotifier[testEvent]([]string{"updated"}, notifier.WithPanicRecovery())
+	_, _ = n.Attach("updated", func(ctx context.Context, e testEvent) error {
+		panic("boom")
+	})
+
+	err := n.Notify(context.TODO(), "updated", testEvent{})
+	if !errors.Is(err, notifier.ErrObserverPanic) {
+		t.Errorf("Notify() error = %v, want %v", err, notifier.ErrObserverPanic)
+	}
+	if !strings.Contains(err.Error(), "TestTopicCallbackNotifier_PanicRecovery") {
+		t.Errorf("Notify() error = %v, want the callback name", err)
+	}
+}
@@ -0,0 +1,17 @@
+package notifier
+
+import "sync"
+
+// Subscription detaches its observer once unsubscribed, unsubscribing again does nothing.
+type Subscription struct {
+	once   *sync.Once
+	detach func()
+}
+
+func newSubscription(detach func()) *Subscription {
+	return &Subscription{once: &sync.Once{}, detach: detach}
+}
+
+func (s *Subscription) Unsubscribe() {
+	s.once.Do(s.detach)
+}
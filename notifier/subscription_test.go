@@ -0,0 +1,83 @@
+package notifier_test
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/davfer/crudo/notifier"
+)
+
+func TestSubscription_Unsubscribe(t *testing.T) {
+	ctx := context.TODO()
+	n := notifier.NewTopicCallbackNotifier[testEvent]([]string{"updated"})
+
+	var once, always atomic.Int32
+	var sub *notifier.Subscription
+	sub, _ = n.Attach("updated", func(ctx context.Context, e testEvent) error {
+		once.Add(1)
+		// observers may unsubscribe while handling an event
+		sub.Unsubscribe()
+		return nil
+	})
+	_, _ = n.Attach("updated", func(ctx context.Context, e testEvent) error {
+		always.Add(1)
+		return nil
+	})
+
+	_ = n.Notify(ctx, "updated", testEvent{})
+	_ = n.Notify(ctx, "updated", testEvent{})
+	sub.Unsubscribe()
+
+	if once.Load() != 1 || always.Load() != 2 {
+		t.Errorf("handled once = %d, always = %d, want 1 and 2", once.Load(), always.Load())
+	}
+}
+
+func TestSubscription_Concurrency(t *testing.T) {
+	ctx := context.TODO()
+	n := notifier.NewTopicCallbackNotifier[testEvent]([]string{"updated"})
+
+	var wg sync.WaitGroup
+	for range 10 {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			for range 100 {
+				sub, err := n.Attach("updated", func(ctx context.Context, e testEvent) error { return nil })
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				sub.Unsubscribe()
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for range 100 {
+				_ = n.Notify(ctx, "updated", testEvent{})
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+type funcObserver func(context.Context, testEvent) error
+
+func (f funcObserver) Handle(ctx context.Context, e testEvent) error {
+	return f(ctx, e)
+}
+
+func TestNotifier_DetachFunc(t *testing.T) {
+	n := notifier.NewNotifier[testEvent]()
+	f := funcObserver(func(context.Context, testEvent) error { return nil })
+	if _, err := n.Attach(f); err != nil {
+		t.Fatal(err)
+	}
+
+	// func observers are not comparable, Detach must not panic on them
+	if err := n.Detach(f); err != nil {
+		t.Errorf("Detach() error = %v", err)
+	}
+}
@@ -3,44 +3,68 @@ package notifier
 import (
 	"context"
 	"fmt"
+	"strings"
+	"sync"
 
+	"github.com/davfer/archit/patterns/opts"
 	"golang.org/x/exp/slices"
 )
 
 type topicObserver[K any] struct {
+	id       uint64
 	topic    string
 	observer Observer[K]
 }
 
+// TopicNotifier delivers events to the observers of their topic, it is safe for concurrent use and observers may
+// unsubscribe while handling an event.
 type TopicNotifier[K any] struct {
 	observers []topicObserver[K]
 	topics    []string
+	policy    Policy
+	lock      *sync.RWMutex
+	lastID    uint64
 }
 
-func NewTopicNotifier[K any](topics []string) *TopicNotifier[K] {
+func NewTopicNotifier[K any](topics []string, o ...opts.Opt[Policy]) *TopicNotifier[K] {
 	return &TopicNotifier[K]{
 		observers: []topicObserver[K]{},
 		topics:    topics,
+		policy:    newPolicy(o...),
+		lock:      &sync.RWMutex{},
 	}
 }
 
-func (n *TopicNotifier[K]) Attach(topic string, observer Observer[K]) error {
-	if !slices.Contains(n.topics, topic) {
-		return fmt.Errorf("topic %s not found", topic)
+// Attach subscribes the observer to the topics matching the given one. Topics are made of dot separated segments, a *
+// segment matches any segment and a trailing * matches the remaining ones, e.g. * matches every topic and users.*
+// matches users.updated.
+func (n *TopicNotifier[K]) Attach(topic string, observer Observer[K]) (*Subscription, error) {
+	if !slices.ContainsFunc(n.topics, func(t string) bool { return MatchTopic(topic, t) }) {
+		return nil, fmt.Errorf("topic %s not found", topic)
 	}
 
+	n.lock.Lock()
+	defer n.lock.Unlock()
+
+	n.lastID++
+	id := n.lastID
 	n.observers = append(n.observers, topicObserver[K]{
+		id:       id,
 		topic:    topic,
 		observer: observer,
 	})
 
-	return nil
+	return newSubscription(func() { n.detach(id) }), nil
 }
 
+// Detach removes the first attachment of the observer, prefer the Subscription returned by Attach.
 func (n *TopicNotifier[K]) Detach(observer Observer[K]) error {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+
 	for i, h := range n.observers {
-		if h.observer == observer {
-			n.observers = append(n.observers[:i], n.observers[i+1:]...)
+		if same(h.observer, observer) {
+			n.observers = append(n.observers[:i:i], n.observers[i+1:]...)
 			break
 		}
 	}
@@ -48,16 +72,63 @@ func (n *TopicNotifier[K]) Detach(observer Observer[K]) error {
 	return nil
 }
 
+// Notify delivers the event to the observers of the topic, which is available to them through TopicFromContext. The
+// returned error joins the ObserverError of each failing observer.
 func (n *TopicNotifier[K]) Notify(ctx context.Context, topic string, event K) error {
-	var err error
+	ctx = WithTopic(ctx, topic)
+
+	n.lock.RLock()
+	var observers []Observer[K]
 	for _, o := range n.observers {
-		if o.topic == topic {
-			err = o.observer.Handle(ctx, event)
-			if err != nil {
-				err = fmt.Errorf("failed to handle event: %w", err)
-			}
+		if MatchTopic(o.topic, topic) {
+			observers = append(observers, o.observer)
+		}
+	}
+	n.lock.RUnlock()
+
+	return notifyAll(ctx, n.policy, topic, observers, event)
+}
+
+func (n *TopicNotifier[K]) detach(id uint64) {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+
+	for i, h := range n.observers {
+		if h.id == id {
+			n.observers = append(n.observers[:i:i], n.observers[i+1:]...)
+			break
+		}
+	}
+}
+
+type topicKey struct{}
+
+// WithTopic returns a context handling an event of topic, e.g. to replay it.
+func WithTopic(ctx context.Context, topic string) context.Context {
+	return context.WithValue(ctx, topicKey{}, topic)
+}
+
+// TopicFromContext returns the topic of the event being handled.
+func TopicFromContext(ctx context.Context) (string, bool) {
+	topic, ok := ctx.Value(topicKey{}).(string)
+	return topic, ok
+}
+
+// MatchTopic tells whether topic matches the pattern, see TopicNotifier.Attach.
+func MatchTopic(pattern, topic string) bool {
+	if pattern == topic {
+		return true
+	}
+
+	ps, ts := strings.Split(pattern, "."), strings.Split(topic, ".")
+	for i, p := range ps {
+		if p == "*" && i == len(ps)-1 {
+			return len(ts) >= len(ps)
+		}
+		if i >= len(ts) || (p != "*" && p != ts[i]) {
+			return false
 		}
 	}
 
-	return err
+	return len(ps) == len(ts)
 }
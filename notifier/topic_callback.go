@@ -1,22 +1,30 @@
 package notifier
 
-import "context"
+import (
+	"context"
+
+	"github.com/davfer/archit/patterns/opts"
+	"github.com/davfer/go-specification"
+)
 
 type TopicCallbackNotifier[K any] struct {
 	notifier *TopicNotifier[K]
 }
 
-func NewTopicCallbackNotifier[K any](topics []string) *TopicCallbackNotifier[K] {
+func NewTopicCallbackNotifier[K any](topics []string, o ...opts.Opt[Policy]) *TopicCallbackNotifier[K] {
 	t := &TopicCallbackNotifier[K]{
-		notifier: NewTopicNotifier[K](topics),
+		notifier: NewTopicNotifier[K](topics, o...),
 	}
 
 	return t
 }
 
-func (t *TopicCallbackNotifier[K]) Attach(topic string, observer ObserverCallback[K]) error {
+// Attach subscribes the callback to the topic, callbacks can only be detached through the returned Subscription. When
+// filters are given only the events satisfying all of them are delivered.
+func (t *TopicCallbackNotifier[K]) Attach(topic string, observer ObserverCallback[K], filters ...specification.Criteria) (*Subscription, error) {
 	cb := &topicSubscriber[K]{
-		cb: observer,
+		cb:      observer,
+		filters: filters,
 	}
 
 	return t.notifier.Attach(topic, cb)
@@ -27,9 +35,20 @@ func (t *TopicCallbackNotifier[K]) Notify(ctx context.Context, topic string, eve
 }
 
 type topicSubscriber[K any] struct {
-	cb ObserverCallback[K]
+	cb      ObserverCallback[K]
+	filters []specification.Criteria
 }
 
 func (t *topicSubscriber[K]) Handle(ctx context.Context, event K) error {
+	for _, f := range t.filters {
+		if !f.IsSatisfiedBy(event) {
+			return nil
+		}
+	}
+
 	return t.cb(ctx, event)
 }
+
+func (t *topicSubscriber[K]) callback() any {
+	return t.cb
+}
@@ -0,0 +1,49 @@
+package notifier_test
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/davfer/crudo/notifier"
+)
+
+func TestTopicNotifier_Wildcard(t *testing.T) {
+	topics := []string{"users.added", "users.updated", "users.profile.updated", "orders.updated"}
+	tests := []struct {
+		name    string
+		pattern string
+		want    []string
+		wantErr bool
+	}{
+		{name: "Test exact topic", pattern: "users.updated", want: []string{"users.updated"}},
+		{name: "Test wildcard", pattern: "*", want: topics},
+		{name: "Test trailing wildcard", pattern: "users.*", want: []string{"users.added", "users.updated", "users.profile.updated"}},
+		{name: "Test segment wildcard", pattern: "*.updated", want: []string{"users.updated", "orders.updated"}},
+		{name: "Test unknown topic", pattern: "users.deleted", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n := notifier.NewTopicCallbackNotifier[testEvent](topics)
+
+			var got []string
+			_, err := n.Attach(tt.pattern, func(ctx context.Context, e testEvent) error {
+				topic, _ := notifier.TopicFromContext(ctx)
+				got = append(got, topic)
+				return nil
+			})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Attach() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			for _, topic := range topics {
+				if err = n.Notify(context.TODO(), topic, testEvent{}); err != nil {
+					t.Fatalf("Notify() error = %v", err)
+				}
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Notify() delivered topics = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,60 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/davfer/crudo/entity"
+	"github.com/davfer/crudo/notifier"
+)
+
+// Record is a change event waiting in the outbox to be published.
+type Record struct {
+	Key         string     `json:"key" bson:"key"` // Key deduplicates the deliveries of the record
+	Topic       string     `json:"topic" bson:"topic"`
+	EntityID    entity.ID  `json:"entity_id" bson:"entity_id"`
+	Payload     []byte     `json:"payload" bson:"payload"` // Payload is the JSON encoded change event
+	CreatedAt   time.Time  `json:"created_at" bson:"created_at"`
+	PublishedAt *time.Time `json:"published_at,omitempty" bson:"published_at,omitempty"`
+}
+
+func NewRecord[K entity.Entity](event notifier.ChangeEvent[K]) (Record, error) {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return Record{}, fmt.Errorf("could not encode change event: %w", err)
+	}
+
+	return Record{
+		Key:       uuid.New().String(),
+		Topic:     event.Type,
+		EntityID:  event.ID,
+		Payload:   payload,
+		CreatedAt: event.Timestamp,
+	}, nil
+}
+
+// NewChangeRecord builds the record of a change made with ctx, see notifier.NewChangeEvent.
+func NewChangeRecord[K entity.Entity](ctx context.Context, topic string, before, after K, sequence uint64) (Record, error) {
+	return NewRecord(notifier.NewChangeEvent(ctx, topic, before, after, sequence))
+}
+
+// Decode returns the change event of the record.
+func Decode[K entity.Entity](r Record) (e notifier.ChangeEvent[K], err error) {
+	if err = json.Unmarshal(r.Payload, &e); err != nil {
+		return e, fmt.Errorf("could not decode outbox record %s: %w", r.Key, err)
+	}
+
+	return e, nil
+}
+
+type keyKey struct{}
+
+// KeyFromContext returns the dedupe key of the record being published, observers may use it to skip redeliveries.
+func KeyFromContext(ctx context.Context) (string, bool) {
+	key, ok := ctx.Value(keyKey{}).(string)
+	return key, ok
+}
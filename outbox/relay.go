@@ -0,0 +1,192 @@
+package outbox
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/davfer/archit/patterns/opts"
+	"github.com/go-logr/logr"
+
+	"github.com/davfer/crudo/entity"
+	"github.com/davfer/crudo/notifier"
+)
+
+// Relay publishes the outbox records in order with at-least-once delivery: a record is acknowledged only once
+// published, so it is published again after a crash in between. Observers may deduplicate with KeyFromContext.
+type Relay[K entity.Entity] struct {
+	Interval  time.Duration
+	BatchSize int
+	store     Store
+	publisher notifier.Publisher[notifier.ChangeEvent[K]]
+	logger    logr.Logger
+	lock      *sync.Mutex
+	unacked   map[string]struct{} // unacked records were published but could not be acknowledged yet
+	cancel    context.CancelFunc
+	done      chan struct{}
+}
+
+func NewRelay[K entity.Entity](store Store, publisher notifier.Publisher[notifier.ChangeEvent[K]], o ...opts.Opt[Relay[K]]) *Relay[K] {
+	r := opts.New[Relay[K]](o...)
+
+	r.store = store
+	r.publisher = publisher
+	if r.Interval <= 0 {
+		r.Interval = time.Second
+	}
+	if r.BatchSize <= 0 {
+		r.BatchSize = 100
+	}
+	if r.logger.GetSink() == nil {
+		r.logger = logr.Discard()
+	}
+	r.lock = &sync.Mutex{}
+	r.unacked = map[string]struct{}{}
+
+	return &r
+}
+
+func WithRelayInterval[K entity.Entity](interval time.Duration) opts.Opt[Relay[K]] {
+	return func(r Relay[K]) Relay[K] {
+		r.Interval = interval
+		return r
+	}
+}
+
+func WithRelayBatchSize[K entity.Entity](size int) opts.Opt[Relay[K]] {
+	return func(r Relay[K]) Relay[K] {
+		r.BatchSize = size
+		return r
+	}
+}
+
+func WithRelayLogger[K entity.Entity](logger logr.Logger) opts.Opt[Relay[K]] {
+	return func(r Relay[K]) Relay[K] {
+		r.logger = logger
+		return r
+	}
+}
+
+// Start relays the pending records every interval until ctx is done or Close is called.
+func (r *Relay[K]) Start(ctx context.Context) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if r.done != nil {
+		return fmt.Errorf("relay already started")
+	}
+
+	ctx, r.cancel = context.WithCancel(ctx)
+	r.done = make(chan struct{})
+	go r.run(ctx, r.done)
+
+	return nil
+}
+
+// Close stops relaying and waits for the running batch to finish.
+func (r *Relay[K]) Close() error {
+	r.lock.Lock()
+	cancel, done := r.cancel, r.done
+	r.cancel, r.done = nil, nil
+	r.lock.Unlock()
+
+	if cancel == nil {
+		return nil
+	}
+
+	cancel()
+	<-done
+
+	return nil
+}
+
+// Relay publishes one batch of pending records and returns how many were published. A record failing to publish stops
+// the batch so the next one starts from it, keeping the records in order.
+func (r *Relay[K]) Relay(ctx context.Context) (int, error) {
+	records, err := r.store.Pending(ctx, r.BatchSize)
+	if err != nil {
+		return 0, fmt.Errorf("could not read pending records: %w", err)
+	}
+
+	var published []string
+	var publishErr error
+	for _, record := range records {
+		if r.isUnacked(record.Key) {
+			// published already, only the checkpoint is missing
+			published = append(published, record.Key)
+			continue
+		}
+
+		event, err := Decode[K](record)
+		if err == nil {
+			err = r.publisher.Notify(context.WithValue(ctx, keyKey{}, record.Key), record.Topic, event)
+		}
+		if err != nil {
+			publishErr = fmt.Errorf("could not publish record %s: %w", record.Key, err)
+			break
+		}
+		published = append(published, record.Key)
+	}
+
+	if len(published) > 0 {
+		if err = r.store.Ack(ctx, published...); err != nil {
+			r.remember(published)
+			return len(published), fmt.Errorf("could not acknowledge records: %w", err)
+		}
+		r.forget(published)
+	}
+
+	return len(published), publishErr
+}
+
+func (r *Relay[K]) run(ctx context.Context, done chan struct{}) {
+	defer close(done)
+
+	ticker := time.NewTicker(r.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		// drain the backlog before waiting again
+		for {
+			n, err := r.Relay(ctx)
+			if err != nil && ctx.Err() == nil {
+				r.logger.Error(err, "error relaying outbox records")
+			}
+			if err != nil || n < r.BatchSize {
+				break
+			}
+		}
+	}
+}
+
+func (r *Relay[K]) isUnacked(key string) bool {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	_, ok := r.unacked[key]
+	return ok
+}
+
+func (r *Relay[K]) remember(keys []string) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	for _, k := range keys {
+		r.unacked[k] = struct{}{}
+	}
+}
+
+func (r *Relay[K]) forget(keys []string) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	for _, k := range keys {
+		delete(r.unacked, k)
+	}
+}
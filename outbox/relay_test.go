@@ -0,0 +1,139 @@
+package outbox_test
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/davfer/crudo/entity"
+	"github.com/davfer/crudo/inmemory"
+	"github.com/davfer/crudo/notifier"
+	"github.com/davfer/crudo/outbox"
+)
+
+type testOutboxEntity struct {
+	Id    string
+	Attr1 string
+}
+
+func (t *testOutboxEntity) GetID() entity.ID {
+	return entity.ID(t.Id)
+}
+
+func (t *testOutboxEntity) SetID(id entity.ID) error {
+	t.Id = string(id)
+	return nil
+}
+
+func (t *testOutboxEntity) GetResourceID() (string, error) {
+	return t.Attr1, nil
+}
+
+func (t *testOutboxEntity) SetResourceID(s string) error {
+	t.Attr1 = s
+	return nil
+}
+
+// flakyStore fails acknowledging records while failAcks is set.
+type flakyStore struct {
+	*outbox.MemoryStore
+	failAcks bool
+}
+
+func (s *flakyStore) Ack(ctx context.Context, keys ...string) error {
+	if s.failAcks {
+		return errors.New("outbox down")
+	}
+	return s.MemoryStore.Ack(ctx, keys...)
+}
+
+func TestRelay(t *testing.T) {
+	ctx := context.TODO()
+	store := &flakyStore{MemoryStore: outbox.NewMemoryStore()}
+	repo := outbox.NewRepository[*testOutboxEntity](inmemory.NewRepository([]*testOutboxEntity{}, inmemory.WithIsolation[*testOutboxEntity]()), store)
+
+	n := notifier.NewTopicCallbackNotifier[notifier.ChangeEvent[*testOutboxEntity]]([]string{notifier.Added, notifier.Updated, notifier.Deleted})
+	var got []string
+	failing := true
+	_, _ = n.Attach("*", func(ctx context.Context, c notifier.ChangeEvent[*testOutboxEntity]) error {
+		if _, ok := outbox.KeyFromContext(ctx); !ok {
+			t.Errorf("record key missing")
+		}
+		if c.Type == notifier.Updated && failing {
+			return errors.New("observer down")
+		}
+		got = append(got, c.Type+":"+c.ID.String())
+		return nil
+	})
+	relay := outbox.NewRelay[*testOutboxEntity](store, n, outbox.WithRelayBatchSize[*testOutboxEntity](10))
+
+	e, _ := repo.Create(ctx, &testOutboxEntity{Id: "1", Attr1: "one"})
+	_ = repo.Update(ctx, &testOutboxEntity{Id: "1", Attr1: "two"})
+	_ = repo.Delete(ctx, e)
+
+	// a failing record stops the batch to keep the order
+	if published, err := relay.Relay(ctx); err == nil || published != 1 {
+		t.Errorf("Relay() published = %d, error = %v, want 1 and an error", published, err)
+	}
+
+	// published records failing to be acknowledged are not published again
+	failing = false
+	store.failAcks = true
+	if _, err := relay.Relay(ctx); err == nil {
+		t.Errorf("Relay() expected ack error")
+	}
+	store.failAcks = false
+	if published, err := relay.Relay(ctx); err != nil || published != 2 {
+		t.Errorf("Relay() published = %d, error = %v, want 2", published, err)
+	}
+	if pending, _ := store.Pending(ctx, 0); len(pending) != 0 {
+		t.Errorf("Pending() got = %v, want none", pending)
+	}
+
+	if !reflect.DeepEqual(got, []string{"added:1", "updated:1", "deleted:1"}) {
+		t.Errorf("published = %v", got)
+	}
+}
+
+func TestRelay_Decode(t *testing.T) {
+	record, err := outbox.NewChangeRecord(notifier.WithActor(context.TODO(), "alice"), notifier.Updated, &testOutboxEntity{Id: "1", Attr1: "one"}, &testOutboxEntity{Id: "1", Attr1: "two"}, 7)
+	if err != nil {
+		t.Fatalf("NewChangeRecord() error = %v", err)
+	}
+
+	c, err := outbox.Decode[*testOutboxEntity](record)
+	if err != nil {
+		t.Fatalf("Decode() error = %v", err)
+	}
+	if c.Type != notifier.Updated || c.Actor != "alice" || c.Sequence != 7 || c.Old.Attr1 != "one" || c.New.Attr1 != "two" || record.EntityID != "1" {
+		t.Errorf("Decode() got = %+v", c)
+	}
+}
+
+func TestRepository_InPlaceUpdate(t *testing.T) {
+	ctx := context.TODO()
+	store := outbox.NewMemoryStore()
+	repo := outbox.NewRepository[*testOutboxEntity](inmemory.NewRepository([]*testOutboxEntity{{Id: "1", Attr1: "one"}}, inmemory.WithIsolation[*testOutboxEntity]()), store)
+
+	e, err := repo.Read(ctx, "1")
+	if err != nil {
+		t.Fatalf("Read() error = %v", err)
+	}
+	e.Attr1 = "two"
+	if err = repo.Update(ctx, e); err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+
+	records := store.Records()
+	if len(records) != 1 {
+		t.Fatalf("Records() got %d, want 1", len(records))
+	}
+	c, err := outbox.Decode[*testOutboxEntity](records[0])
+	if err != nil {
+		t.Fatalf("Decode() error = %v", err)
+	}
+	if c.Old.Attr1 != "one" || c.New.Attr1 != "two" || c.Sequence != 1 {
+		t.Errorf("Decode() got = %+v, want one to two", c)
+	}
+}
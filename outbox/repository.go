@@ -0,0 +1,71 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+
+	"github.com/davfer/crudo"
+	"github.com/davfer/crudo/entity"
+	"github.com/davfer/crudo/inmemory"
+	"github.com/davfer/crudo/notifier"
+)
+
+// Repository decorates a repository appending a record of every write to the outbox. The write and the append are not
+// atomic, repositories supporting transactions should write both at once instead, see the mongo packages.
+type Repository[K entity.Entity] struct {
+	crudo.Repository[K]
+	Codec    inmemory.Codec[K] // Codec copies the previous state of updated entities that are not inmemory.Cloneable
+	outbox   Store
+	sequence *atomic.Uint64
+}
+
+func NewRepository[K entity.Entity](repo crudo.Repository[K], outbox Store) *Repository[K] {
+	return &Repository[K]{Repository: repo, Codec: inmemory.JSONCodec[K]{}, outbox: outbox, sequence: &atomic.Uint64{}}
+}
+
+func (r *Repository[K]) Create(ctx context.Context, e K) (K, error) {
+	e, err := r.Repository.Create(ctx, e)
+	if err != nil {
+		return e, err
+	}
+
+	return e, r.append(ctx, notifier.Added, *new(K), e)
+}
+
+func (r *Repository[K]) Update(ctx context.Context, e K) error {
+	old, err := r.Repository.Read(ctx, e.GetID())
+	if err != nil && !errors.Is(err, entity.ErrEntityNotFound) {
+		return fmt.Errorf("could not read entity: %w", err)
+	} else if err == nil {
+		if old, err = inmemory.Clone(old, r.Codec); err != nil {
+			return err
+		}
+	}
+	if err = r.Repository.Update(ctx, e); err != nil {
+		return err
+	}
+
+	return r.append(ctx, notifier.Updated, old, e)
+}
+
+func (r *Repository[K]) Delete(ctx context.Context, e K) error {
+	if err := r.Repository.Delete(ctx, e); err != nil {
+		return err
+	}
+
+	return r.append(ctx, notifier.Deleted, e, *new(K))
+}
+
+func (r *Repository[K]) append(ctx context.Context, topic string, before, after K) error {
+	record, err := NewChangeRecord(ctx, topic, before, after, r.sequence.Add(1))
+	if err != nil {
+		return err
+	}
+	if err = r.outbox.Append(ctx, record); err != nil {
+		return fmt.Errorf("could not append outbox record: %w", err)
+	}
+
+	return nil
+}
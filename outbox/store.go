@@ -0,0 +1,88 @@
+package outbox
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Store keeps the records until they are published. Records are returned in append order and acknowledging them is
+// the checkpoint of the relay.
+type Store interface {
+	Append(ctx context.Context, records ...Record) error
+	Pending(ctx context.Context, limit int) ([]Record, error)
+	Ack(ctx context.Context, keys ...string) error
+}
+
+// MemoryStore is an in-memory Store, meant for tests.
+type MemoryStore struct {
+	lock    *sync.Mutex
+	records []Record
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{lock: &sync.Mutex{}}
+}
+
+// Append ignores the records whose key was already appended.
+func (s *MemoryStore) Append(_ context.Context, records ...Record) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	for _, r := range records {
+		if s.indexOf(r.Key) < 0 {
+			s.records = append(s.records, r)
+		}
+	}
+
+	return nil
+}
+
+func (s *MemoryStore) Pending(_ context.Context, limit int) ([]Record, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	var pending []Record
+	for _, r := range s.records {
+		if r.PublishedAt == nil {
+			pending = append(pending, r)
+		}
+		if limit > 0 && len(pending) == limit {
+			break
+		}
+	}
+
+	return pending, nil
+}
+
+func (s *MemoryStore) Ack(_ context.Context, keys ...string) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	now := time.Now()
+	for _, k := range keys {
+		if i := s.indexOf(k); i >= 0 {
+			s.records[i].PublishedAt = &now
+		}
+	}
+
+	return nil
+}
+
+// Records returns every record, published or not.
+func (s *MemoryStore) Records() []Record {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	return append([]Record{}, s.records...)
+}
+
+func (s *MemoryStore) indexOf(key string) int {
+	for i, r := range s.records {
+		if r.Key == key {
+			return i
+		}
+	}
+
+	return -1
+}
@@ -0,0 +1,45 @@
+package store
+
+import (
+	"context"
+	"time"
+
+	"github.com/davfer/crudo/entity"
+)
+
+// ConflictResolver decides the state of an entity changed both locally and remotely since the last refresh.
+type ConflictResolver[K entity.Entity] interface {
+	Resolve(ctx context.Context, local, remote K) (K, error)
+}
+
+// ConflictResolverFunc adapts a merge function to a ConflictResolver.
+type ConflictResolverFunc[K entity.Entity] func(ctx context.Context, local, remote K) (K, error)
+
+func (f ConflictResolverFunc[K]) Resolve(ctx context.Context, local, remote K) (K, error) {
+	return f(ctx, local, remote)
+}
+
+type RemoteWins[K entity.Entity] struct{}
+
+func (RemoteWins[K]) Resolve(_ context.Context, _, remote K) (K, error) {
+	return remote, nil
+}
+
+type LocalWins[K entity.Entity] struct{}
+
+func (LocalWins[K]) Resolve(_ context.Context, local, _ K) (K, error) {
+	return local, nil
+}
+
+// LastWriterWins keeps the most recently updated side, the remote one on ties.
+type LastWriterWins[K entity.Entity] struct {
+	UpdatedAt func(K) time.Time
+}
+
+func (l LastWriterWins[K]) Resolve(_ context.Context, local, remote K) (K, error) {
+	if l.UpdatedAt(local).After(l.UpdatedAt(remote)) {
+		return local, nil
+	}
+
+	return remote, nil
+}
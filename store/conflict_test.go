@@ -0,0 +1,124 @@
+package store_test
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/davfer/archit/patterns/opts"
+	"github.com/davfer/crudo/inmemory"
+	"github.com/davfer/crudo/notifier"
+	"github.com/davfer/crudo/store"
+)
+
+func TestProxyStore_RefreshConflicts(t *testing.T) {
+	updatedAt := map[string]time.Time{
+		"local":  time.Unix(200, 0),
+		"remote": time.Unix(100, 0),
+	}
+	tests := []struct {
+		name          string
+		resolver      store.ConflictResolver[*testProxyEntity]
+		changeLocal   bool
+		changeRemote  bool
+		want          string
+		wantConflicts []string
+	}{
+		{
+			name:          "Test remote wins by default",
+			changeLocal:   true,
+			changeRemote:  true,
+			want:          "remote",
+			wantConflicts: []string{"local->remote"},
+		},
+		{
+			name:          "Test local wins",
+			resolver:      store.LocalWins[*testProxyEntity]{},
+			changeLocal:   true,
+			changeRemote:  true,
+			want:          "local",
+			wantConflicts: []string{"local->local"},
+		},
+		{
+			name: "Test last writer wins",
+			resolver: store.LastWriterWins[*testProxyEntity]{UpdatedAt: func(e *testProxyEntity) time.Time {
+				return updatedAt[e.Attr1]
+			}},
+			changeLocal:   true,
+			changeRemote:  true,
+			want:          "local",
+			wantConflicts: []string{"local->local"},
+		},
+		{
+			name: "Test custom merge",
+			resolver: store.ConflictResolverFunc[*testProxyEntity](func(ctx context.Context, local, remote *testProxyEntity) (*testProxyEntity, error) {
+				return &testProxyEntity{Id: local.Id, Attr1: local.Attr1 + "+" + remote.Attr1}, nil
+			}),
+			changeLocal:   true,
+			changeRemote:  true,
+			want:          "local+remote",
+			wantConflicts: []string{"local->local+remote"},
+		},
+		{
+			name:        "Test only local changed is pushed",
+			resolver:    store.RemoteWins[*testProxyEntity]{},
+			changeLocal: true,
+			want:        "local",
+		},
+		{
+			name:         "Test only remote changed is pulled",
+			resolver:     store.LocalWins[*testProxyEntity]{},
+			changeRemote: true,
+			want:         "remote",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.TODO()
+			remote := inmemory.NewRepository(
+				[]*testProxyEntity{{Id: "1", Attr1: "base"}},
+				inmemory.WithIsolation[*testProxyEntity](),
+			)
+
+			local := inmemory.NewRepository([]*testProxyEntity{}, inmemory.WithIsolation[*testProxyEntity]())
+			o := []opts.Opt[store.ProxyStore[*testProxyEntity]]{store.WithLocalRepository[*testProxyEntity](local)}
+			if tt.resolver != nil {
+				o = append(o, store.WithConflictResolver(tt.resolver))
+			}
+			s := store.NewProxyStore(o...)
+			s.RefreshPolicy = store.RefreshPolicyReadWriteAll
+
+			var conflicts []string
+			_, _ = s.OnChange(store.Conflict, func(ctx context.Context, c notifier.ChangeEvent[*testProxyEntity]) error {
+				conflicts = append(conflicts, c.Old.Attr1+"->"+c.New.Attr1)
+				return nil
+			})
+			if err := s.Load(ctx, remote); err != nil {
+				t.Fatalf("Load() error = %v", err)
+			}
+
+			if tt.changeLocal {
+				// a local only change, e.g. made while the remote was unreachable
+				_ = local.Update(ctx, &testProxyEntity{Id: "1", Attr1: "local"})
+			}
+			if tt.changeRemote {
+				_ = remote.Update(ctx, &testProxyEntity{Id: "1", Attr1: "remote"})
+			}
+
+			if err := s.Refresh(ctx); err != nil {
+				t.Fatalf("Refresh() error = %v", err)
+			}
+
+			if local, _ := s.Read(ctx, "1"); local.Attr1 != tt.want {
+				t.Errorf("Refresh() local = %v, want %v", local.Attr1, tt.want)
+			}
+			if r, _ := remote.Read(ctx, "1"); r.Attr1 != tt.want {
+				t.Errorf("Refresh() remote = %v, want %v", r.Attr1, tt.want)
+			}
+			if !reflect.DeepEqual(conflicts, tt.wantConflicts) {
+				t.Errorf("Refresh() conflicts = %v, want %v", conflicts, tt.wantConflicts)
+			}
+		})
+	}
+}
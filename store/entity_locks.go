@@ -0,0 +1,93 @@
+package store
+
+import (
+	"sync"
+
+	"github.com/davfer/crudo/entity"
+)
+
+type entityLock struct {
+	mutex sync.Mutex
+	refs  int
+}
+
+// entityLocks serializes the writes and the refresh of each entity. While a refresh runs it records the entities
+// written meanwhile, so the refresh does not apply its stale view of them.
+type entityLocks struct {
+	lock    *sync.Mutex
+	locks   map[entity.ID]*entityLock
+	written map[entity.ID]struct{} // written is nil when no refresh runs
+}
+
+func newEntityLocks() *entityLocks {
+	return &entityLocks{
+		lock:  &sync.Mutex{},
+		locks: map[entity.ID]*entityLock{},
+	}
+}
+
+// write locks the entity for a change, the returned func unlocks it.
+func (l *entityLocks) write(id entity.ID) func() {
+	el := l.acquire(id)
+
+	return func() {
+		l.lock.Lock()
+		if l.written != nil {
+			l.written[id] = struct{}{}
+		}
+		l.lock.Unlock()
+		l.release(id, el)
+	}
+}
+
+// refresh locks the entity for a refresh and tells whether it was written since the refresh started.
+func (l *entityLocks) refresh(id entity.ID) (unlock func(), written bool) {
+	el := l.acquire(id)
+
+	l.lock.Lock()
+	_, written = l.written[id]
+	l.lock.Unlock()
+
+	return func() { l.release(id, el) }, written
+}
+
+// track starts recording the written entities, untrack stops it.
+func (l *entityLocks) track() {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	l.written = map[entity.ID]struct{}{}
+}
+
+func (l *entityLocks) untrack() {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	l.written = nil
+}
+
+func (l *entityLocks) acquire(id entity.ID) *entityLock {
+	l.lock.Lock()
+	el, ok := l.locks[id]
+	if !ok {
+		el = &entityLock{}
+		l.locks[id] = el
+	}
+	el.refs++
+	l.lock.Unlock()
+
+	el.mutex.Lock()
+	return el
+}
+
+func (l *entityLocks) release(id entity.ID, el *entityLock) {
+	el.mutex.Unlock()
+
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	el.refs--
+	if el.refs == 0 {
+		delete(l.locks, id)
+	}
+}
@@ -0,0 +1,40 @@
+package store
+
+import (
+	"reflect"
+
+	"github.com/davfer/go-specification"
+
+	"github.com/davfer/crudo/entity"
+	"github.com/davfer/crudo/notifier"
+)
+
+// changeFilter satisfies the change events whose previous or new entity satisfies its criteria, so observers learn
+// about entities leaving the filter as well as entering it.
+type changeFilter[K entity.Entity] struct {
+	criteria specification.Criteria
+}
+
+func (f changeFilter[K]) IsSatisfiedBy(v any) bool {
+	c, ok := v.(notifier.ChangeEvent[K])
+	if !ok {
+		return false
+	}
+
+	return (!isEmpty(c.Old) && f.criteria.IsSatisfiedBy(c.Old)) || (!isEmpty(c.New) && f.criteria.IsSatisfiedBy(c.New))
+}
+
+func changeFilters[K entity.Entity](filters []specification.Criteria) []specification.Criteria {
+	result := make([]specification.Criteria, len(filters))
+	for i, f := range filters {
+		result[i] = changeFilter[K]{criteria: f}
+	}
+
+	return result
+}
+
+// isEmpty tells whether e is the missing side of a change.
+func isEmpty[K entity.Entity](e K) bool {
+	v := reflect.ValueOf(e)
+	return !v.IsValid() || (v.Kind() == reflect.Pointer && v.IsNil())
+}
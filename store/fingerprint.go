@@ -0,0 +1,23 @@
+package store
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+
+	"github.com/davfer/crudo/entity"
+)
+
+// FingerprintFunc summarizes the content of an entity, two entities with the same fingerprint are considered equal.
+type FingerprintFunc[K entity.Entity] func(K) (string, error)
+
+// FingerprintJSON hashes the JSON encoding of the entity, it is the default fingerprint.
+func FingerprintJSON[K entity.Entity](e K) (string, error) {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
@@ -0,0 +1,114 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+
+	"github.com/davfer/archit/patterns/opts"
+	"github.com/davfer/go-specification"
+
+	"github.com/davfer/crudo"
+	"github.com/davfer/crudo/entity"
+	"github.com/davfer/crudo/inmemory"
+	"github.com/davfer/crudo/notifier"
+)
+
+// ObservedRepository decorates any repository publishing its writes as Added, Updated and Deleted events, both to entity
+// and to change observers.
+type ObservedRepository[K entity.Entity] struct {
+	crudo.Repository[K]
+	Codec    inmemory.Codec[K] // Codec copies the previous state of updated entities that are not inmemory.Cloneable
+	notifier *notifier.TopicCallbackNotifier[K]
+	changes  *notifier.TopicCallbackNotifier[notifier.ChangeEvent[K]]
+	sequence *atomic.Uint64
+}
+
+func NewObservedRepository[K entity.Entity](repo crudo.Repository[K], o ...opts.Opt[notifier.Policy]) *ObservedRepository[K] {
+	topics := []string{Added, Updated, Deleted}
+
+	return &ObservedRepository[K]{
+		Repository: repo,
+		notifier:   notifier.NewTopicCallbackNotifier[K](topics, o...),
+		changes:    notifier.NewTopicCallbackNotifier[notifier.ChangeEvent[K]](topics, o...),
+		sequence:   &atomic.Uint64{},
+		Codec:      inmemory.JSONCodec[K]{},
+	}
+}
+
+func (r *ObservedRepository[K]) On(event string, observer notifier.ObserverCallback[K], filters ...specification.Criteria) (*notifier.Subscription, error) {
+	return r.notifier.Attach(event, observer, filters...)
+}
+
+// OnChange attaches an observer receiving both the previous and the new state of the entity
+func (r *ObservedRepository[K]) OnChange(event string, observer notifier.ObserverCallback[notifier.ChangeEvent[K]], filters ...specification.Criteria) (*notifier.Subscription, error) {
+	return r.changes.Attach(event, observer, changeFilters[K](filters)...)
+}
+
+func (r *ObservedRepository[K]) Create(ctx context.Context, e K) (K, error) {
+	e, err := r.Repository.Create(ctx, e)
+	if err != nil {
+		return e, err
+	}
+	if err = r.notify(ctx, Added, *new(K), e); err != nil {
+		return e, fmt.Errorf("could not notify entity add: %w", err)
+	}
+
+	return e, nil
+}
+
+// Update reads the previous state of the entity before updating it, so change observers receive both. Repositories
+// handing out the stored entities, e.g. a non isolated inmemory one, have no previous state left of the ones modified
+// in place.
+func (r *ObservedRepository[K]) Update(ctx context.Context, e K) error {
+	old, err := previous(ctx, r.Repository, e.GetID(), r.Codec)
+	if err != nil {
+		return err
+	}
+
+	if err = r.Repository.Update(ctx, e); err != nil {
+		return err
+	}
+	if err = r.notify(ctx, Updated, old, e); err != nil {
+		return fmt.Errorf("could not notify entity update: %w", err)
+	}
+
+	return nil
+}
+
+func (r *ObservedRepository[K]) Delete(ctx context.Context, e K) error {
+	if err := r.Repository.Delete(ctx, e); err != nil {
+		return err
+	}
+	if err := r.notify(ctx, Deleted, e, *new(K)); err != nil {
+		return fmt.Errorf("could not notify entity delete: %w", err)
+	}
+
+	return nil
+}
+
+func (r *ObservedRepository[K]) notify(ctx context.Context, topic string, before, after K) error {
+	e := after
+	if topic == Deleted {
+		e = before
+	}
+
+	if err := r.notifier.Notify(ctx, topic, e); err != nil {
+		return err
+	}
+
+	return r.changes.Notify(ctx, topic, notifier.NewChangeEvent(ctx, topic, before, after, r.sequence.Add(1)))
+}
+
+// previous returns a copy of the stored entity, empty if there is none.
+func previous[K entity.Entity](ctx context.Context, repo crudo.Repository[K], id entity.ID, codec inmemory.Codec[K]) (K, error) {
+	e, err := repo.Read(ctx, id)
+	if errors.Is(err, entity.ErrEntityNotFound) {
+		return *new(K), nil
+	} else if err != nil {
+		return e, fmt.Errorf("could not read entity: %w", err)
+	}
+
+	return inmemory.Clone(e, codec)
+}
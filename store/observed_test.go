@@ -0,0 +1,103 @@
+package store_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/davfer/crudo"
+	"github.com/davfer/crudo/entity"
+	"github.com/davfer/crudo/inmemory"
+	"github.com/davfer/crudo/notifier"
+	"github.com/davfer/crudo/store"
+	"github.com/davfer/go-specification"
+)
+
+func TestObservedRepository(t *testing.T) {
+	ctx := notifier.WithCorrelationID(notifier.WithActor(context.TODO(), "alice"), "req-1")
+	r := store.NewObservedRepository(inmemory.NewRepository([]*testProxyEntity{}, inmemory.WithIsolation[*testProxyEntity]()))
+
+	var events []notifier.ChangeEvent[*testProxyEntity]
+	var deleted []string
+	_, _ = r.OnChange("*", func(ctx context.Context, c notifier.ChangeEvent[*testProxyEntity]) error {
+		events = append(events, c)
+		return nil
+	})
+	_, _ = r.On(store.Deleted, func(ctx context.Context, e *testProxyEntity) error {
+		deleted = append(deleted, e.Id)
+		return nil
+	})
+
+	e, err := r.Create(ctx, &testProxyEntity{Attr1: "one"})
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	if err = r.Update(ctx, &testProxyEntity{Id: e.Id, Attr1: "two"}); err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+	if err = r.Delete(ctx, e); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+
+	if len(events) != 3 || len(deleted) != 1 {
+		t.Fatalf("got %d change events and %d deletions, want 3 and 1", len(events), len(deleted))
+	}
+	for i, c := range events {
+		if c.ID != entity.ID(e.Id) || c.Actor != "alice" || c.CorrelationID != "req-1" || c.Sequence != uint64(i+1) || c.Timestamp.IsZero() {
+			t.Errorf("event %d = %+v", i, c)
+		}
+	}
+	if update := events[1]; update.Type != store.Updated || update.Old.Attr1 != "one" || update.New.Attr1 != "two" {
+		t.Errorf("update event = %+v", update)
+	}
+	if events[2].Type != store.Deleted || events[2].New != nil {
+		t.Errorf("delete event = %+v", events[2])
+	}
+}
+
+// TestObservedRepository_InPlaceUpdate modifies read entities in place, which keeps their previous state with isolated
+// repositories.
+func TestObservedRepository_InPlaceUpdate(t *testing.T) {
+	type observed interface {
+		crudo.Repository[*testProxyEntity]
+		OnChange(string, notifier.ObserverCallback[notifier.ChangeEvent[*testProxyEntity]], ...specification.Criteria) (*notifier.Subscription, error)
+	}
+	tests := []struct {
+		name string
+		repo func(t *testing.T) observed
+	}{
+		{"observed", func(t *testing.T) observed {
+			return store.NewObservedRepository(inmemory.NewRepository([]*testProxyEntity{{Id: "1", Attr1: "one"}}, inmemory.WithIsolation[*testProxyEntity]()))
+		}},
+		{"store", func(t *testing.T) observed {
+			s := store.NewProxyStore(store.WithLocalOptions(inmemory.WithIsolation[*testProxyEntity]()))
+			if err := s.Load(context.TODO(), inmemory.NewRepository([]*testProxyEntity{{Id: "1", Attr1: "one"}}, inmemory.WithIsolation[*testProxyEntity]())); err != nil {
+				t.Fatalf("Load() error = %v", err)
+			}
+			return s
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.TODO()
+			r := tt.repo(t)
+			var got []notifier.ChangeEvent[*testProxyEntity]
+			_, _ = r.OnChange(store.Updated, func(ctx context.Context, c notifier.ChangeEvent[*testProxyEntity]) error {
+				got = append(got, c)
+				return nil
+			})
+
+			e, err := r.Read(ctx, "1")
+			if err != nil {
+				t.Fatalf("Read() error = %v", err)
+			}
+			e.Attr1 = "two"
+			if err = r.Update(ctx, e); err != nil {
+				t.Fatalf("Update() error = %v", err)
+			}
+
+			if len(got) != 1 || got[0].Old.Attr1 != "one" || got[0].New.Attr1 != "two" {
+				t.Errorf("Update() events = %+v, want one from one to two", got)
+			}
+		})
+	}
+}
@@ -0,0 +1,155 @@
+package store
+
+import (
+	"time"
+
+	"github.com/davfer/archit/patterns/opts"
+	"github.com/davfer/crudo"
+	"github.com/davfer/crudo/entity"
+	"github.com/davfer/crudo/inmemory"
+	"github.com/davfer/crudo/notifier"
+	"github.com/davfer/go-specification"
+	"github.com/go-logr/logr"
+)
+
+// WithFingerprint sets how entity contents are compared on refresh, e.g. by a version or updated-at field.
+func WithFingerprint[K entity.Entity](f FingerprintFunc[K]) opts.Opt[ProxyStore[K]] {
+	return func(s ProxyStore[K]) ProxyStore[K] {
+		s.fingerprint = f
+		return s
+	}
+}
+
+// WithWriteBehind applies writes locally and flushes them to the remote repository every interval, in batches of at
+// most batchSize writes retried up to retries times. Created entities are flushed with the id they got locally, so ids
+// generates ids the remote repository accepts, e.g. mongo.ObjectIDStrategy, and the local repository must not generate
+// its own. Entities created with an id keep it. Writes are queued as copies, taken before hydrating created entities,
+// see WithCodec.
+func WithWriteBehind[K entity.Entity](ids inmemory.IdStrategy[K], interval time.Duration, batchSize, retries int) opts.Opt[ProxyStore[K]] {
+	return func(s ProxyStore[K]) ProxyStore[K] {
+		s.writes = newWriteQueue[K](interval, batchSize, retries)
+		s.writes.ids = ids
+		return s
+	}
+}
+
+func WithLogger[K entity.Entity](logger logr.Logger) opts.Opt[ProxyStore[K]] {
+	return func(s ProxyStore[K]) ProxyStore[K] {
+		s.logger = logger
+		return s
+	}
+}
+
+// WithConflictResolver sets how RefreshPolicyReadWriteAll settles entities changed on both sides, remote wins by
+// default.
+func WithConflictResolver[K entity.Entity](c ConflictResolver[K]) opts.Opt[ProxyStore[K]] {
+	return func(s ProxyStore[K]) ProxyStore[K] {
+		s.resolver = c
+		return s
+	}
+}
+
+// WithScope restricts the store to the remote entities satisfying c, they are loaded, refreshed and read through with
+// it.
+func WithScope[K entity.Entity](c specification.Criteria) opts.Opt[ProxyStore[K]] {
+	return func(s ProxyStore[K]) ProxyStore[K] {
+		s.scope = c
+		return s
+	}
+}
+
+// WithCodec sets how the entities that are not inmemory.Cloneable are copied, e.g. the previous state of an update,
+// JSON by default.
+func WithCodec[K entity.Entity](c inmemory.Codec[K]) opts.Opt[ProxyStore[K]] {
+	return func(s ProxyStore[K]) ProxyStore[K] {
+		s.codec = c
+		return s
+	}
+}
+
+// WithLazyLoading makes Load only wire the remote repository, entities are cached as they are read or matched instead.
+func WithLazyLoading[K entity.Entity]() opts.Opt[ProxyStore[K]] {
+	return func(s ProxyStore[K]) ProxyStore[K] {
+		s.lazy = true
+		return s
+	}
+}
+
+// WithLocalOptions configures the local in-memory repository, e.g. a bounded policy to use the store as an LRU cache.
+// It has no effect along with WithLocalRepository.
+func WithLocalOptions[K entity.Entity](o ...opts.Opt[inmemory.Repository[K]]) opts.Opt[ProxyStore[K]] {
+	return func(s ProxyStore[K]) ProxyStore[K] {
+		s.localOptions = append(s.localOptions, o...)
+		return s
+	}
+}
+
+// WithNegativeCache answers reads of entities missing remotely as not found for ttl, without asking the remote again.
+func WithNegativeCache[K entity.Entity](ttl time.Duration) opts.Opt[ProxyStore[K]] {
+	return func(s ProxyStore[K]) ProxyStore[K] {
+		s.reads = newReadGroup[K](ttl)
+		return s
+	}
+}
+
+// WithHydrateConcurrency hydrates the entities of Load and Refresh running up to workers hydrations at once.
+func WithHydrateConcurrency[K entity.Entity](workers int) opts.Opt[ProxyStore[K]] {
+	return func(s ProxyStore[K]) ProxyStore[K] {
+		s.hydrateWorkers = workers
+		return s
+	}
+}
+
+// WithLocalRepository uses l as the local tier instead of an in-memory repository, e.g. a persistent one in front of a
+// slower remote. It must be started beforehand, Load makes it mirror the remote entities unless loading lazily.
+func WithLocalRepository[K entity.Entity](l crudo.Repository[K]) opts.Opt[ProxyStore[K]] {
+	return func(s ProxyStore[K]) ProxyStore[K] {
+		s.localRepository = l
+		return s
+	}
+}
+
+// WithAsyncNotify delivers the notifications from a pool of workers instead of the write path. The topics of the store
+// share the queues, so the notifications of an entity keep their order across them, and a full queue of queueSize
+// events applies backpressure.
+func WithAsyncNotify[K entity.Entity](workers, queueSize int, backpressure notifier.Backpressure) opts.Opt[ProxyStore[K]] {
+	return func(s ProxyStore[K]) ProxyStore[K] {
+		s.async = &asyncNotify{workers: workers, queueSize: queueSize, backpressure: backpressure}
+		return s
+	}
+}
+
+// WithOutbox leaves notifying the writes of the store to an outbox: the remote repository appends a record of every
+// write, e.g. outbox.Repository or mongo.OutboxRepository, and an outbox.Relay publishes them through NotifyChange. So
+// observers are told of every write once its record is relayed, even if the process stops right after the write.
+func WithOutbox[K entity.Entity]() opts.Opt[ProxyStore[K]] {
+	return func(s ProxyStore[K]) ProxyStore[K] {
+		s.outboxed = true
+		return s
+	}
+}
+
+// WithNotifyPolicy sets how failing observers are dealt with, e.g. retried or recovered from panics.
+func WithNotifyPolicy[K entity.Entity](o ...opts.Opt[notifier.Policy]) opts.Opt[ProxyStore[K]] {
+	return func(s ProxyStore[K]) ProxyStore[K] {
+		s.notifyPolicy = append(s.notifyPolicy, o...)
+		return s
+	}
+}
+
+type asyncNotify struct {
+	workers      int
+	queueSize    int
+	backpressure notifier.Backpressure
+}
+
+func dispatcherOptions[E any](a *asyncNotify, key func(E) string, logger logr.Logger) []opts.Opt[notifier.Dispatcher[E]] {
+	return []opts.Opt[notifier.Dispatcher[E]]{
+		notifier.WithWorkers[E](a.workers),
+		notifier.WithQueueSize[E](a.queueSize),
+		notifier.WithBackpressure[E](a.backpressure),
+		notifier.WithKey(key),
+		notifier.WithQueueOf[E](func(string) string { return "entities" }),
+		notifier.WithDispatcherLogger[E](logger),
+	}
+}
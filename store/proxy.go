@@ -4,7 +4,10 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"sync"
+	"sync/atomic"
 
+	"github.com/davfer/archit/patterns/opts"
 	"github.com/davfer/go-specification"
 	"github.com/go-logr/logr"
 
@@ -17,9 +20,10 @@ import (
 const (
 	Loaded   = "loaded"
 	Unloaded = "unloaded"
-	Added    = "added"
-	Updated  = "updated"
-	Deleted  = "deleted"
+	Added    = notifier.Added
+	Updated  = notifier.Updated
+	Deleted  = notifier.Deleted
+	Conflict = "conflict"
 )
 
 type HydrateFunc[K entity.Entity] func(ctx context.Context, entity K) (K, error)
@@ -32,25 +36,95 @@ const (
 	RefreshPolicyReadWriteAll RefreshPolicy = "read-write-all"
 )
 
+// ProxyStore is safe for concurrent use once configured, Hydrate and RefreshPolicy must be set before Load.
 type ProxyStore[K entity.Entity] struct {
 	remoteRepository crudo.Repository[K]
 	localRepository  crudo.Repository[K]
+	wiring           *sync.RWMutex
+	entities         *entityLocks
 	RefreshPolicy    RefreshPolicy
 	notifier         *notifier.TopicCallbackNotifier[K]
+	changes          *notifier.TopicCallbackNotifier[notifier.ChangeEvent[K]]
+	notifyPolicy     []opts.Opt[notifier.Policy]
+	async            *asyncNotify
+	events           notifier.Publisher[K]
+	changeEvents     notifier.Publisher[notifier.ChangeEvent[K]]
+	dispatchers      []interface{ Close(context.Context) error }
+	sequence         *atomic.Uint64
 	Hydrate          HydrateFunc[K]
+	fingerprint      FingerprintFunc[K]
 	logger           logr.Logger
+	refreshLock      *sync.Mutex
+	writes           *writeQueue[K]
+	reads            *readGroup[K]
+	resolver         ConflictResolver[K]
+	syncedLock       *sync.Mutex
+	synced           map[entity.ID]syncState
+	scope            specification.Criteria
+	lazy             bool
+	hydrateWorkers   int
+	localOptions     []opts.Opt[inmemory.Repository[K]]
+	outboxed         bool
+	codec            inmemory.Codec[K]
 }
 
-func NewProxyStore[K entity.Entity]() *ProxyStore[K] {
-	return &ProxyStore[K]{
-		RefreshPolicy: RefreshPolicyNone,
-		notifier:      notifier.NewTopicCallbackNotifier[K]([]string{Added, Updated, Deleted, Loaded, Unloaded}),
-		logger:        logr.Discard(),
+func NewProxyStore[K entity.Entity](o ...opts.Opt[ProxyStore[K]]) *ProxyStore[K] {
+	r := opts.New[ProxyStore[K]](o...)
+
+	topics := []string{Added, Updated, Deleted, Loaded, Unloaded, Conflict}
+	if r.RefreshPolicy == "" {
+		r.RefreshPolicy = RefreshPolicyNone
+	}
+	r.notifier = notifier.NewTopicCallbackNotifier[K](topics, r.notifyPolicy...)
+	r.changes = notifier.NewTopicCallbackNotifier[notifier.ChangeEvent[K]](topics, r.notifyPolicy...)
+	r.events, r.changeEvents = r.notifier, r.changes
+	r.sequence = &atomic.Uint64{}
+	if r.codec == nil {
+		r.codec = inmemory.JSONCodec[K]{}
+	}
+	if r.fingerprint == nil {
+		r.fingerprint = FingerprintJSON[K]
+	}
+	if r.logger.GetSink() == nil {
+		r.logger = logr.Discard()
+	}
+	r.wiring = &sync.RWMutex{}
+	r.entities = newEntityLocks()
+	r.refreshLock = &sync.Mutex{}
+	if r.resolver == nil {
+		r.resolver = RemoteWins[K]{}
+	}
+	if r.reads == nil {
+		r.reads = newReadGroup[K](0)
+	}
+	r.syncedLock = &sync.Mutex{}
+	r.synced = map[entity.ID]syncState{}
+	if r.writes != nil {
+		r.writes.logger = r.logger
+		r.writes.onFlushed = r.markFlushed
 	}
+	if r.async != nil {
+		// events of the same entity keep their order
+		events := notifier.NewDispatcher[K](r.notifier, dispatcherOptions(r.async, func(e K) string {
+			return e.GetID().String()
+		}, r.logger)...)
+		changeEvents := notifier.NewDispatcher[notifier.ChangeEvent[K]](r.changes, dispatcherOptions(r.async, func(c notifier.ChangeEvent[K]) string {
+			return c.ID.String()
+		}, r.logger)...)
+		r.events, r.changeEvents = events, changeEvents
+		r.dispatchers = append(r.dispatchers, events, changeEvents)
+	}
+
+	return &r
+}
+
+func (r *ProxyStore[K]) On(event string, observer notifier.ObserverCallback[K], filters ...specification.Criteria) (*notifier.Subscription, error) {
+	return r.notifier.Attach(event, observer, filters...)
 }
 
-func (r *ProxyStore[K]) On(event string, observer notifier.ObserverCallback[K]) error {
-	return r.notifier.Attach(event, observer)
+// OnChange attaches an observer receiving both the previous and the new state of the entity
+func (r *ProxyStore[K]) OnChange(event string, observer notifier.ObserverCallback[notifier.ChangeEvent[K]], filters ...specification.Criteria) (*notifier.Subscription, error) {
+	return r.changes.Attach(event, observer, changeFilters[K](filters)...)
 }
 
 func (r *ProxyStore[K]) OnHydrate(onHydrate HydrateFunc[K]) {
@@ -58,37 +132,48 @@ func (r *ProxyStore[K]) OnHydrate(onHydrate HydrateFunc[K]) {
 }
 
 func (r *ProxyStore[K]) Start(ctx context.Context, onBootstrap func(ctx context.Context) error) error {
-	if r.remoteRepository == nil {
-		return fmt.Errorf("store not loaded")
+	if err := r.loaded(); err != nil {
+		return err
 	}
 
 	return r.remoteRepository.Start(ctx, onBootstrap)
 }
 
 func (r *ProxyStore[K]) Create(ctx context.Context, e K) (K, error) {
-	if r.remoteRepository == nil {
-		return e, fmt.Errorf("store not loaded")
+	if err := r.loaded(); err != nil {
+		return e, err
 	}
 
 	if !e.GetID().IsEmpty() {
 		return e, fmt.Errorf("entity already with id")
 	}
+	if r.writes != nil {
+		return r.createBehind(ctx, e)
+	}
 
 	e, err := r.remoteRepository.Create(ctx, e)
 	if err != nil {
 		return e, fmt.Errorf("could not insert entity: %w", err)
 	}
-	// hydrate persisted entity
-	if r.Hydrate != nil {
-		e, err = r.Hydrate(ctx, e)
+	r.reads.forget(e.GetID())
+	if e, err = r.hydrate(ctx, e); err != nil {
+		return e, err
+	}
+
+	unlock := r.entities.write(e.GetID())
+	r.markSynced(e)
+	if r.inScope(e) {
+		// a concurrent refresh may have loaded it already
+		if _, err = r.localRepository.Create(ctx, e); errors.Is(err, entity.ErrEntityAlreadyExists) {
+			err = r.localRepository.Update(ctx, e)
+		}
 		if err != nil {
-			return e, fmt.Errorf("could not hydrate entity: %w", err)
+			unlock()
+			return e, fmt.Errorf("could not insert entity locally: %w", err)
 		}
 	}
-	if _, err = r.localRepository.Create(ctx, e); err != nil {
-		return e, fmt.Errorf("could not insert entity locally: %w", err)
-	}
-	if err = r.notifier.Notify(ctx, Added, e); err != nil {
+	unlock()
+	if err = r.notifyWrite(ctx, Added, *new(K), e); err != nil {
 		return e, fmt.Errorf("could not notify entity add: %w", err)
 	}
 
@@ -96,8 +181,8 @@ func (r *ProxyStore[K]) Create(ctx context.Context, e K) (K, error) {
 }
 
 func (r *ProxyStore[K]) Read(ctx context.Context, id entity.ID) (e K, err error) {
-	if r.remoteRepository == nil {
-		return e, fmt.Errorf("store not loaded")
+	if err := r.loaded(); err != nil {
+		return e, err
 	}
 
 	e, err = r.localRepository.Read(ctx, id)
@@ -105,166 +190,985 @@ func (r *ProxyStore[K]) Read(ctx context.Context, id entity.ID) (e K, err error)
 		err = fmt.Errorf("could not read entity: %w", err)
 		return
 	} else if err == nil {
+		r.reads.hit()
 		return
 	}
 
+	if r.writes != nil {
+		// the remote copy is about to be deleted
+		if op, ok := r.writes.pendingOp(id); ok && op == Deleted {
+			return e, entity.ErrEntityNotFound
+		}
+	}
+
+	return r.reads.do(ctx, id, func() (K, error) {
+		return r.readRemoteEntity(ctx, id)
+	})
+}
+
+// readRemoteEntity reads a missing entity from the remote repository and caches it locally.
+func (r *ProxyStore[K]) readRemoteEntity(ctx context.Context, id entity.ID) (e K, err error) {
 	e, err = r.remoteRepository.Read(ctx, id)
 	if err != nil && !errors.Is(err, entity.ErrEntityNotFound) {
-		err = fmt.Errorf("could not read entity: %w", err)
-	} else if err == nil {
-		if _, err = r.localRepository.Create(ctx, e); err != nil {
-			r.logger.Error(err, "error creating local entity")
-		}
-		if err = r.notifier.Notify(ctx, Loaded, e); err != nil {
-			r.logger.Error(err, "error notifying entity load")
-			return
-		}
+		return e, fmt.Errorf("could not read entity: %w", err)
+	} else if err == nil && !r.inScope(e) {
+		return *new(K), entity.ErrEntityNotFound
+	} else if err != nil {
+		return
+	}
+
+	if e, err = r.hydrate(ctx, e); err != nil {
+		return
+	}
+	unlock := r.entities.write(id)
+	r.markSynced(e)
+	if _, err = r.localRepository.Create(ctx, e); err != nil {
+		r.logger.Error(err, "error creating local entity")
+	}
+	unlock()
+	if err = r.notify(ctx, Loaded, *new(K), e); err != nil {
+		r.logger.Error(err, "error notifying entity load")
+		return
 	}
 
 	return
 }
 
 func (r *ProxyStore[K]) Match(ctx context.Context, c specification.Criteria) ([]K, error) {
-	if r.remoteRepository == nil {
-		return []K{}, fmt.Errorf("store not loaded")
+	if err := r.loaded(); err != nil {
+		return []K{}, err
+	}
+	if r.lazy {
+		return r.matchThrough(ctx, c)
 	}
 
 	return r.localRepository.Match(ctx, c)
 }
 
 func (r *ProxyStore[K]) MatchOne(ctx context.Context, c specification.Criteria) (K, error) {
-	if r.remoteRepository == nil {
-		return *new(K), fmt.Errorf("store not loaded")
+	if err := r.loaded(); err != nil {
+		return *new(K), err
+	}
+	if r.lazy {
+		entities, err := r.matchThrough(ctx, c)
+		if err != nil {
+			return *new(K), err
+		}
+		if len(entities) == 0 {
+			return *new(K), entity.ErrEntityNotFound
+		}
+		return entities[0], nil
 	}
 
 	return r.localRepository.MatchOne(ctx, c)
 }
 
 func (r *ProxyStore[K]) ReadAll(ctx context.Context) ([]K, error) {
-	if r.remoteRepository == nil {
-		return []K{}, fmt.Errorf("store not loaded")
+	if err := r.loaded(); err != nil {
+		return []K{}, err
+	}
+	if r.lazy {
+		return r.matchThrough(ctx, nil)
 	}
 
 	return r.localRepository.ReadAll(ctx)
 }
 
-func (r *ProxyStore[K]) Update(ctx context.Context, entity K) error {
-	if r.remoteRepository == nil {
-		return fmt.Errorf("store not loaded")
+// Update notifies a copy of the entity stored locally as its previous state, see WithCodec. The default local tier hands
+// out the stored entities, so one modified in place has no previous state left unless the tier is isolated, see
+// inmemory.WithIsolation.
+func (r *ProxyStore[K]) Update(ctx context.Context, e K) error {
+	if err := r.loaded(); err != nil {
+		return err
 	}
 
-	if err := r.remoteRepository.Update(ctx, entity); err != nil {
-		return fmt.Errorf("could not update entity remotely: %w", err)
-	}
-	if err := r.localRepository.Update(ctx, entity); err != nil {
-		return fmt.Errorf("could not update entity locally: %w", err)
+	unlock := r.entities.write(e.GetID())
+	old, err := r.update(ctx, e)
+	unlock()
+	if err != nil {
+		return err
 	}
-	if err := r.notifier.Notify(ctx, Updated, entity); err != nil {
+	if err = r.notifyWrite(ctx, Updated, old, e); err != nil {
 		return fmt.Errorf("could not notify entity update: %w", err)
 	}
 
 	return nil
 }
 
-func (r *ProxyStore[K]) Delete(ctx context.Context, entity K) error {
-	if r.remoteRepository == nil {
-		return fmt.Errorf("store not loaded")
+func (r *ProxyStore[K]) update(ctx context.Context, e K) (old K, err error) {
+	// a copy, the event keeps the previous state even if the stored entity changes later
+	if old, err = previous(ctx, r.localRepository, e.GetID(), r.codec); err != nil {
+		return old, err
 	}
 
-	if err := r.remoteRepository.Delete(ctx, entity); err != nil {
-		return fmt.Errorf("could not delete entity remotely: %w", err)
+	if r.writes != nil {
+		if err = r.enqueue(Updated, e); err != nil {
+			return old, err
+		}
+	} else if err = r.remoteRepository.Update(ctx, e); err != nil {
+		return old, fmt.Errorf("could not update entity remotely: %w", err)
+	} else {
+		r.markSynced(e)
 	}
-	if err := r.localRepository.Delete(ctx, entity); err != nil {
-		return fmt.Errorf("could not delete entity locally: %w", err)
+	if !r.inScope(e) {
+		// the entity left the scope of the store
+		if err = r.localRepository.Delete(ctx, e); err != nil {
+			return old, fmt.Errorf("could not delete entity locally: %w", err)
+		}
+		r.forget(e.GetID())
+	} else if err = r.localRepository.Update(ctx, e); err != nil {
+		return old, fmt.Errorf("could not update entity locally: %w", err)
 	}
-	if err := r.notifier.Notify(ctx, Deleted, entity); err != nil {
+
+	return old, nil
+}
+
+func (r *ProxyStore[K]) Delete(ctx context.Context, e K) error {
+	if err := r.loaded(); err != nil {
+		return err
+	}
+
+	unlock := r.entities.write(e.GetID())
+	err := r.delete(ctx, e)
+	unlock()
+	if err != nil {
+		return err
+	}
+	if err = r.notifyWrite(ctx, Deleted, e, *new(K)); err != nil {
 		return fmt.Errorf("could not notify entity delete: %w", err)
 	}
 
 	return nil
 }
 
+func (r *ProxyStore[K]) delete(ctx context.Context, e K) error {
+	if r.writes != nil {
+		if err := r.enqueue(Deleted, e); err != nil {
+			return err
+		}
+	} else if err := r.remoteRepository.Delete(ctx, e); err != nil {
+		return fmt.Errorf("could not delete entity remotely: %w", err)
+	} else {
+		r.forget(e.GetID())
+	}
+	if err := r.localRepository.Delete(ctx, e); err != nil {
+		return fmt.Errorf("could not delete entity locally: %w", err)
+	}
+
+	return nil
+}
+
+// Load wires the remote repository and loads its entities, a store can be loaded only once. The store cannot be used
+// until Load returns, so Hydrate must not call it.
 func (r *ProxyStore[K]) Load(ctx context.Context, repo crudo.Repository[K]) error {
-	r.remoteRepository = repo
+	if r.writes != nil && r.writes.ids == nil {
+		return fmt.Errorf("write-behind needs an id strategy of the remote repository")
+	}
 
-	if r.localRepository != nil {
+	r.wiring.Lock()
+	if r.remoteRepository != nil {
+		r.wiring.Unlock()
 		return fmt.Errorf("entities already loaded")
 	}
 
-	entities, err := r.remoteRepository.ReadAll(ctx)
+	entities, err := r.load(ctx, repo)
+	r.wiring.Unlock()
 	if err != nil {
-		return fmt.Errorf("could not load Entities: %w", err)
+		return err
 	}
 
-	r.localRepository = inmemory.NewRepository(entities)
 	for _, d := range entities {
-		if r.Hydrate != nil {
-			d, err = r.Hydrate(ctx, d)
-			if err != nil {
-				return fmt.Errorf("could not hydrate entity: %w", err)
-			}
-		}
-		if err = r.notifier.Notify(ctx, Loaded, d); err != nil {
+		if err = r.notify(ctx, Loaded, *new(K), d); err != nil {
 			return err
 		}
 	}
 
 	return nil
 }
+
+// load wires the repositories and returns the loaded entities. Callers must hold the wiring lock.
+func (r *ProxyStore[K]) load(ctx context.Context, repo crudo.Repository[K]) ([]K, error) {
+	r.remoteRepository = repo
+
+	var entities []K
+	var raws []string
+	if !r.lazy {
+		var err error
+		if entities, err = r.readRemote(ctx); err != nil {
+			r.remoteRepository = nil
+			return nil, fmt.Errorf("could not load Entities: %w", err)
+		}
+		if entities, raws, err = r.hydrateRemote(ctx, entities, nil); err != nil {
+			r.remoteRepository = nil
+			return nil, err
+		}
+	}
+	if r.writes != nil {
+		r.writes.start(context.WithoutCancel(ctx), r.remoteRepository)
+	}
+
+	if r.localRepository == nil {
+		// lazy stores cache the entities on demand, the ones evicted are no longer synced
+		r.localRepository = inmemory.NewRepository(append([]K{}, entities...), append(r.localOptions, inmemory.WithOnEvict(func(e K) {
+			r.forget(e.GetID())
+		}))...)
+	} else if !r.lazy {
+		if err := r.populate(ctx, entities); err != nil {
+			r.remoteRepository = nil
+			return nil, err
+		}
+	}
+	for i, d := range entities {
+		r.markSyncedRaw(d, raws[i])
+	}
+
+	return entities, nil
+}
+
+// populate makes a provided local repository mirror the loaded entities, dropping the ones it kept from before.
+func (r *ProxyStore[K]) populate(ctx context.Context, entities []K) error {
+	stale, err := r.localRepository.ReadAll(ctx)
+	if err != nil {
+		return fmt.Errorf("could not read local entities: %w", err)
+	}
+	for _, e := range stale {
+		if entity.Contains(entities, e) {
+			continue
+		}
+		if err = r.localRepository.Delete(ctx, e); err != nil {
+			return fmt.Errorf("could not delete local entity %s: %w", e.GetID(), err)
+		}
+	}
+
+	for _, e := range entities {
+		if entity.Contains(stale, e) {
+			err = r.localRepository.Update(ctx, e)
+		} else {
+			_, err = r.localRepository.Create(ctx, e)
+		}
+		if err != nil {
+			return fmt.Errorf("could not load entity %s locally: %w", e.GetID(), err)
+		}
+	}
+
+	return nil
+}
+
+// Refresh syncs the local and remote entities as set by the RefreshPolicy. Remote entities unchanged since they were
+// last synced are not hydrated again.
 func (r *ProxyStore[K]) Refresh(ctx context.Context) error {
-	if r.remoteRepository == nil {
-		return fmt.Errorf("store not loaded")
+	if err := r.loaded(); err != nil {
+		return err
 	}
 
 	if r.RefreshPolicy == RefreshPolicyNone {
 		return nil
 	}
 
-	remoteEntities, err := r.remoteRepository.ReadAll(ctx)
-	if err != nil {
-		return fmt.Errorf("could not load remote Entities: %w", err)
+	// concurrent refreshes would apply the same diff twice
+	r.refreshLock.Lock()
+	defer r.refreshLock.Unlock()
+
+	if r.writes != nil {
+		if err := r.Flush(ctx); err != nil {
+			r.logger.Error(err, "error flushing writes before refresh")
+		}
 	}
 
+	// entities written from now on are newer than the ones read below
+	r.entities.track()
+	defer r.entities.untrack()
+
 	localEntities, err := r.localRepository.ReadAll(ctx)
 	if err != nil {
 		return fmt.Errorf("could not load local Entities: %w", err)
 	}
+	var remoteEntities []K
+	if r.lazy {
+		// only the cached entities are refreshed
+		remoteEntities, err = r.readCached(ctx, localEntities)
+	} else {
+		remoteEntities, err = r.readRemote(ctx)
+	}
+	if err != nil {
+		return fmt.Errorf("could not load remote Entities: %w", err)
+	}
+	if r.writes != nil {
+		// entities with writes still queued are newer locally
+		localEntities = r.withoutPending(localEntities)
+		remoteEntities = r.withoutPending(remoteEntities)
+	}
+	// remote entities are compared and stored as the local ones, hydrated
+	var raws []string
+	if remoteEntities, raws, err = r.hydrateRemote(ctx, remoteEntities, localEntities); err != nil {
+		return err
+	}
+	if localEntities, err = r.unloadOutOfScope(ctx, localEntities); err != nil {
+		return err
+	}
 
 	if r.RefreshPolicy == RefreshPolicyReadAll || r.RefreshPolicy == RefreshPolicyReadWriteAll {
-		for _, d := range remoteEntities {
-			if !entity.Contains(localEntities, d) {
-				if _, err = r.localRepository.Create(ctx, d); err != nil {
-					return err
+		for i, d := range remoteEntities {
+			l, found := find(localEntities, d.GetID())
+			err = r.refreshEntity(ctx, d.GetID(), func() (*change[K], error) {
+				if !found {
+					if _, err := r.localRepository.Create(ctx, d); err != nil {
+						return nil, err
+					}
+					r.markSyncedRaw(d, raws[i])
+					return &change[K]{topic: Loaded, after: d}, nil
+				}
+
+				if changed, err := r.changed(l, d); err != nil {
+					return nil, err
+				} else if !changed {
+					r.markSyncedRaw(d, raws[i])
+					return nil, nil
+				} else if r.RefreshPolicy == RefreshPolicyReadWriteAll {
+					return r.reconcile(ctx, l, d)
 				}
-				if err = r.notifier.Notify(ctx, Loaded, d); err != nil {
-					return err
+				// remote copy wins over a stale local one
+				if err := r.localRepository.Update(ctx, d); err != nil {
+					return nil, err
 				}
+				r.markSyncedRaw(d, raws[i])
+				return &change[K]{topic: Updated, before: l, after: d}, nil
+			})
+			if err != nil {
+				return err
 			}
 		}
 	}
 
 	if r.RefreshPolicy == RefreshPolicyWriteAll || r.RefreshPolicy == RefreshPolicyReadWriteAll {
 		for _, d := range localEntities {
-			if !entity.Contains(remoteEntities, d) {
-				_, err = r.remoteRepository.Create(ctx, d)
-				if err != nil {
-					return err
+			_, found := find(remoteEntities, d.GetID())
+			err = r.refreshEntity(ctx, d.GetID(), func() (*change[K], error) {
+				// remote copies are never overwritten, they may hold changes made by others
+				if found {
+					return nil, nil
+				}
+				if _, err := r.remoteRepository.Create(ctx, d); err != nil {
+					return nil, err
 				}
+				r.markSynced(d)
+				return nil, nil
+			})
+			if err != nil {
+				return err
 			}
 		}
 	} else {
 		// unload not found
 		for _, d := range localEntities {
-			if !entity.Contains(remoteEntities, d) {
-				if err = r.localRepository.Delete(ctx, d); err != nil {
-					return err
-				}
-				if err = r.notifier.Notify(ctx, Unloaded, d); err != nil {
-					return err
-				}
+			if entity.Contains(remoteEntities, d) {
+				continue
+			}
+			if err = r.refreshEntity(ctx, d.GetID(), func() (*change[K], error) {
+				return r.unload(ctx, d)
+			}); err != nil {
+				return err
 			}
 		}
 	}
 
 	return nil
 }
+
+// ApplyChange applies a change made to the remote repository by someone else, e.g. published by a mongo Watch, so
+// the local entities follow the remote ones between refreshes. Entities with queued writes keep the local state.
+func (r *ProxyStore[K]) ApplyChange(ctx context.Context, topic string, c notifier.ChangeEvent[K]) error {
+	if err := r.loaded(); err != nil {
+		return err
+	}
+	if c.ID.IsEmpty() {
+		return nil
+	}
+	if r.writes != nil {
+		if _, ok := r.writes.pendingOp(c.ID); ok {
+			return nil
+		}
+	}
+	if topic == Added || topic == Updated {
+		r.reads.forget(c.ID)
+	}
+
+	unlock := r.entities.write(c.ID)
+	ch, err := r.applyChange(ctx, topic, c)
+	unlock()
+	if err != nil || ch == nil {
+		return err
+	}
+
+	return r.notify(ctx, ch.topic, ch.before, ch.after)
+}
+
+// NotifyChange notifies the observers of a write relayed from the outbox of a store using WithOutbox, e.g. as the
+// publisher of an outbox.Relay. The local entities are not changed, the store applied the write already.
+func (r *ProxyStore[K]) NotifyChange(ctx context.Context, topic string, c notifier.ChangeEvent[K]) error {
+	if err := r.loaded(); err != nil {
+		return err
+	}
+
+	return r.publish(ctx, topic, c)
+}
+
+func (r *ProxyStore[K]) applyChange(ctx context.Context, topic string, c notifier.ChangeEvent[K]) (*change[K], error) {
+	l, err := r.localRepository.Read(ctx, c.ID)
+	if err != nil && !errors.Is(err, entity.ErrEntityNotFound) {
+		return nil, fmt.Errorf("could not read entity locally: %w", err)
+	}
+	found := err == nil
+
+	switch topic {
+	case Added, Updated:
+		if !r.inScope(c.New) {
+			if found {
+				return r.unload(ctx, l)
+			}
+			return nil, nil
+		}
+		if r.lazy && !found {
+			// only the cached entities follow the remote ones
+			return nil, nil
+		}
+		e, err := r.hydrate(ctx, c.New)
+		if err != nil {
+			return nil, err
+		}
+		return r.store(ctx, e)
+	case Deleted:
+		if !found {
+			return nil, nil
+		}
+		return r.unload(ctx, l)
+	}
+
+	return nil, nil
+}
+
+// change is a refresh outcome notified once the entity is unlocked, so observers may write it back.
+type change[K entity.Entity] struct {
+	topic  string
+	before K
+	after  K
+}
+
+// refreshEntity applies the refresh of an entity unless it was written since the refresh started.
+func (r *ProxyStore[K]) refreshEntity(ctx context.Context, id entity.ID, apply func() (*change[K], error)) error {
+	unlock, written := r.entities.refresh(id)
+	if written {
+		unlock()
+		return nil
+	}
+
+	c, err := apply()
+	unlock()
+	if err != nil || c == nil {
+		return err
+	}
+
+	return r.notify(ctx, c.topic, c.before, c.after)
+}
+
+func (r *ProxyStore[K]) unload(ctx context.Context, e K) (*change[K], error) {
+	if err := r.localRepository.Delete(ctx, e); err != nil {
+		return nil, err
+	}
+	r.forget(e.GetID())
+
+	return &change[K]{topic: Unloaded, before: e}, nil
+}
+
+// Flush applies every queued write of a write-behind store to the remote repository. Writes failing are kept queued
+// for a later flush until they run out of retries.
+func (r *ProxyStore[K]) Flush(ctx context.Context) error {
+	if r.writes == nil || r.loaded() != nil {
+		return nil
+	}
+
+	for r.writes.Stats().Pending > 0 {
+		if err := r.writes.flush(ctx, r.remoteRepository); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Close stops the background flushing of a write-behind store and flushes the pending writes, then drains the
+// asynchronous notifications.
+func (r *ProxyStore[K]) Close(ctx context.Context) error {
+	var errs []error
+	if r.writes != nil {
+		r.writes.stop()
+		errs = append(errs, r.Flush(ctx))
+	}
+	for _, d := range r.dispatchers {
+		errs = append(errs, d.Close(ctx))
+	}
+
+	return errors.Join(errs...)
+}
+
+// ReadStats returns the read-through cache metrics.
+func (r *ProxyStore[K]) ReadStats() ReadStats {
+	return r.reads.Stats()
+}
+
+// WriteStats returns the write-behind queue metrics.
+func (r *ProxyStore[K]) WriteStats() WriteBehindStats {
+	if r.writes == nil {
+		return WriteBehindStats{}
+	}
+
+	return r.writes.Stats()
+}
+
+func (r *ProxyStore[K]) loaded() error {
+	r.wiring.RLock()
+	defer r.wiring.RUnlock()
+
+	if r.remoteRepository == nil {
+		return fmt.Errorf("store not loaded")
+	}
+
+	return nil
+}
+
+func (r *ProxyStore[K]) inScope(e K) bool {
+	return r.scope == nil || r.scope.IsSatisfiedBy(e)
+}
+
+// readRemote reads the remote entities within the scope of the store.
+func (r *ProxyStore[K]) readRemote(ctx context.Context) ([]K, error) {
+	if r.scope == nil {
+		return r.remoteRepository.ReadAll(ctx)
+	}
+
+	return r.remoteRepository.Match(ctx, r.scope)
+}
+
+// readCached reads the remote copies of the cached entities one by one, leaving out the ones missing or out of scope.
+func (r *ProxyStore[K]) readCached(ctx context.Context, local []K) ([]K, error) {
+	result := make([]K, 0, len(local))
+	for _, l := range local {
+		e, err := r.remoteRepository.Read(ctx, l.GetID())
+		if errors.Is(err, entity.ErrEntityNotFound) {
+			continue
+		} else if err != nil {
+			return nil, fmt.Errorf("could not read entity %s: %w", l.GetID(), err)
+		}
+		if r.inScope(e) {
+			result = append(result, e)
+		}
+	}
+
+	return result, nil
+}
+
+// unloadOutOfScope drops the local entities that no longer satisfy the scope and returns the remaining ones, so they
+// are neither written back nor kept around.
+func (r *ProxyStore[K]) unloadOutOfScope(ctx context.Context, entities []K) ([]K, error) {
+	if r.scope == nil {
+		return entities, nil
+	}
+
+	result := make([]K, 0, len(entities))
+	for _, e := range entities {
+		if r.inScope(e) {
+			result = append(result, e)
+			continue
+		}
+
+		if err := r.refreshEntity(ctx, e.GetID(), func() (*change[K], error) {
+			return r.unload(ctx, e)
+		}); err != nil {
+			return nil, err
+		}
+	}
+
+	return result, nil
+}
+
+// matchThrough matches c remotely, nil matching every entity in scope, and caches the results locally. Queued writes
+// are flushed first so the remote results include them.
+func (r *ProxyStore[K]) matchThrough(ctx context.Context, c specification.Criteria) ([]K, error) {
+	if err := r.Flush(ctx); err != nil {
+		return []K{}, fmt.Errorf("could not flush writes: %w", err)
+	}
+
+	var entities []K
+	var err error
+	if c == nil {
+		entities, err = r.readRemote(ctx)
+	} else {
+		entities, err = r.remoteRepository.Match(ctx, c)
+	}
+	if err != nil {
+		return []K{}, fmt.Errorf("could not match entities remotely: %w", err)
+	}
+
+	result := make([]K, 0, len(entities))
+	for _, e := range entities {
+		if !r.inScope(e) {
+			continue
+		}
+		if e, err = r.hydrate(ctx, e); err != nil {
+			return []K{}, err
+		}
+		if err = r.cache(ctx, e); err != nil {
+			return []K{}, err
+		}
+		result = append(result, e)
+	}
+
+	return result, nil
+}
+
+// cache stores a hydrated remote entity locally, replacing a stale local copy.
+func (r *ProxyStore[K]) cache(ctx context.Context, e K) error {
+	unlock := r.entities.write(e.GetID())
+	c, err := r.store(ctx, e)
+	unlock()
+	if err != nil || c == nil {
+		return err
+	}
+
+	return r.notify(ctx, c.topic, c.before, c.after)
+}
+
+func (r *ProxyStore[K]) store(ctx context.Context, e K) (*change[K], error) {
+	l, err := r.localRepository.Read(ctx, e.GetID())
+	if err != nil && !errors.Is(err, entity.ErrEntityNotFound) {
+		return nil, fmt.Errorf("could not read entity locally: %w", err)
+	}
+
+	if err != nil {
+		if _, err = r.localRepository.Create(ctx, e); err != nil {
+			return nil, fmt.Errorf("could not insert entity locally: %w", err)
+		}
+		r.markSynced(e)
+		return &change[K]{topic: Loaded, after: e}, nil
+	}
+
+	if changed, err := r.changed(l, e); err != nil || !changed {
+		return nil, err
+	}
+	if err = r.localRepository.Update(ctx, e); err != nil {
+		return nil, fmt.Errorf("could not update entity locally: %w", err)
+	}
+	r.markSynced(e)
+
+	return &change[K]{topic: Updated, before: l, after: e}, nil
+}
+
+func (r *ProxyStore[K]) createBehind(ctx context.Context, e K) (K, error) {
+	// the remote repository must keep the id, so it is not left to the local one
+	if e.GetID().IsEmpty() {
+		if err := e.SetID(r.writes.ids.Generate(e)); err != nil {
+			return e, fmt.Errorf("could not set generated entity id: %w", err)
+		}
+	}
+	e, err := r.localRepository.Create(ctx, e)
+	if err != nil {
+		return e, fmt.Errorf("could not insert entity locally: %w", err)
+	}
+	r.reads.forget(e.GetID())
+
+	unlock := r.entities.write(e.GetID())
+	// queued before hydrating, the remote gets what write-through would write
+	if err = r.enqueue(Added, e); err == nil && !r.inScope(e) {
+		if err = r.localRepository.Delete(ctx, e); err != nil {
+			err = fmt.Errorf("could not delete entity locally: %w", err)
+		}
+	}
+	unlock()
+	if err != nil {
+		return e, err
+	}
+
+	if r.Hydrate != nil {
+		if e, err = r.hydrate(ctx, e); err != nil {
+			return e, err
+		}
+		if r.inScope(e) {
+			if err = r.localRepository.Update(ctx, e); err != nil {
+				return e, fmt.Errorf("could not update hydrated entity locally: %w", err)
+			}
+		}
+	}
+	if err = r.notifyWrite(ctx, Added, *new(K), e); err != nil {
+		return e, fmt.Errorf("could not notify entity add: %w", err)
+	}
+
+	return e, nil
+}
+
+// enqueue queues a remote write of a copy of e, so the caller may keep modifying it.
+func (r *ProxyStore[K]) enqueue(op string, e K) error {
+	c, err := inmemory.Clone(e, r.codec)
+	if err != nil {
+		return fmt.Errorf("could not queue entity write: %w", err)
+	}
+	r.writes.enqueue(op, c)
+
+	return nil
+}
+
+// hydrate completes an entity before it is stored locally.
+func (r *ProxyStore[K]) hydrate(ctx context.Context, e K) (K, error) {
+	if r.Hydrate == nil {
+		return e, nil
+	}
+
+	h, err := r.Hydrate(ctx, e)
+	if err != nil {
+		return e, fmt.Errorf("could not hydrate entity %s: %w", e.GetID(), err)
+	}
+
+	return h, nil
+}
+
+// hydrateRemote hydrates the remote entities and returns them along with the fingerprints of their raw state. The ones
+// unchanged on both sides since they were last synced are not hydrated again, their local copy is returned instead.
+func (r *ProxyStore[K]) hydrateRemote(ctx context.Context, remote, local []K) ([]K, []string, error) {
+	result := make([]K, len(remote))
+	raws := make([]string, len(remote))
+	var changed []K
+	var at []int
+	for i, e := range remote {
+		// taken before hydrating, which may modify e in place
+		if raw, err := r.fingerprint(e); err == nil {
+			raws[i] = raw
+		}
+		if l, ok := r.unchanged(e, raws[i], local); ok {
+			result[i] = l
+			continue
+		}
+		changed = append(changed, e)
+		at = append(at, i)
+	}
+
+	hydrated, err := r.hydrateAll(ctx, changed)
+	if err != nil {
+		return nil, nil, err
+	}
+	for j, i := range at {
+		result[i] = hydrated[j]
+	}
+
+	return result, raws, nil
+}
+
+// unchanged returns the local copy of a remote entity when neither side changed since it was last synced.
+func (r *ProxyStore[K]) unchanged(e K, raw string, local []K) (K, bool) {
+	r.syncedLock.Lock()
+	s, known := r.synced[e.GetID()]
+	r.syncedLock.Unlock()
+	if !known || s.raw == "" || s.raw != raw {
+		return *new(K), false
+	}
+
+	l, found := find(local, e.GetID())
+	if !found {
+		return *new(K), false
+	}
+	fl, err := r.fingerprint(l)
+
+	return l, err == nil && fl == s.fingerprint
+}
+
+// hydrateAll hydrates the entities running up to hydrateWorkers hydrations at once.
+func (r *ProxyStore[K]) hydrateAll(ctx context.Context, entities []K) ([]K, error) {
+	if r.Hydrate == nil {
+		return entities, nil
+	}
+
+	result := make([]K, len(entities))
+	errs := make([]error, len(entities))
+	sem := make(chan struct{}, max(r.hydrateWorkers, 1))
+	var wg sync.WaitGroup
+	for i, e := range entities {
+		sem <- struct{}{}
+		wg.Add(1)
+		go func() {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			result[i], errs[i] = r.hydrate(ctx, e)
+		}()
+	}
+	wg.Wait()
+
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+func (r *ProxyStore[K]) withoutPending(entities []K) []K {
+	result := make([]K, 0, len(entities))
+	for _, e := range entities {
+		if _, ok := r.writes.pendingOp(e.GetID()); !ok {
+			result = append(result, e)
+		}
+	}
+
+	return result
+}
+
+// reconcile settles an entity that differs on both sides using the fingerprint of the last synced state: a side
+// still matching it is stale and takes the other one, otherwise both changed and the conflict resolver decides.
+func (r *ProxyStore[K]) reconcile(ctx context.Context, local, remote K) (*change[K], error) {
+	fl, err := r.fingerprint(local)
+	if err != nil {
+		return nil, fmt.Errorf("could not fingerprint entity %s: %w", local.GetID(), err)
+	}
+	fr, err := r.fingerprint(remote)
+	if err != nil {
+		return nil, fmt.Errorf("could not fingerprint entity %s: %w", remote.GetID(), err)
+	}
+
+	r.syncedLock.Lock()
+	s, known := r.synced[local.GetID()]
+	r.syncedLock.Unlock()
+	base := s.fingerprint
+
+	switch {
+	case known && fl == base:
+		if err = r.localRepository.Update(ctx, remote); err != nil {
+			return nil, err
+		}
+		r.markSynced(remote)
+		return &change[K]{topic: Updated, before: local, after: remote}, nil
+	case known && fr == base:
+		if err = r.remoteRepository.Update(ctx, local); err != nil {
+			return nil, err
+		}
+		r.markSynced(local)
+		return nil, nil
+	}
+
+	resolved, err := r.resolver.Resolve(ctx, local, remote)
+	if err != nil {
+		return nil, fmt.Errorf("could not resolve conflict on entity %s: %w", local.GetID(), err)
+	}
+	fres, err := r.fingerprint(resolved)
+	if err != nil {
+		return nil, fmt.Errorf("could not fingerprint entity %s: %w", resolved.GetID(), err)
+	}
+	if fres != fl {
+		if err = r.localRepository.Update(ctx, resolved); err != nil {
+			return nil, err
+		}
+	}
+	if fres != fr {
+		if err = r.remoteRepository.Update(ctx, resolved); err != nil {
+			return nil, err
+		}
+	}
+	r.markSynced(resolved)
+
+	return &change[K]{topic: Conflict, before: local, after: resolved}, nil
+}
+
+// syncState is the last state of an entity known to be equal on both sides, as fingerprints of the hydrated entity and
+// of the raw remote one, if it was read.
+type syncState struct {
+	fingerprint string
+	raw         string
+}
+
+// markSynced records the state of an entity known to be equal on both sides.
+func (r *ProxyStore[K]) markSynced(e K) {
+	r.markSyncedRaw(e, "")
+}
+
+// markSyncedRaw records the state of an entity read from the remote repository, raw being the fingerprint of the entity
+// as read, before hydrating it.
+func (r *ProxyStore[K]) markSyncedRaw(e K, raw string) {
+	fp, err := r.fingerprint(e)
+	if err != nil {
+		r.logger.Error(err, "error fingerprinting entity", "id", e.GetID())
+		return
+	}
+
+	r.syncedLock.Lock()
+	defer r.syncedLock.Unlock()
+
+	r.synced[e.GetID()] = syncState{fingerprint: fp, raw: raw}
+}
+
+func (r *ProxyStore[K]) forget(id entity.ID) {
+	r.syncedLock.Lock()
+	defer r.syncedLock.Unlock()
+
+	delete(r.synced, id)
+}
+
+func (r *ProxyStore[K]) markFlushed(op string, e K) {
+	if op == Deleted {
+		r.forget(e.GetID())
+		return
+	}
+
+	r.markSynced(e)
+}
+
+// notify publishes the change to the entity observers and the change observers. Entity observers receive the new
+// state, or the old one for removals.
+func (r *ProxyStore[K]) notify(ctx context.Context, topic string, before, after K) error {
+	return r.publish(ctx, topic, notifier.NewChangeEvent(ctx, topic, before, after, r.sequence.Add(1)))
+}
+
+// notifyWrite notifies a write of the store, unless its event is relayed from an outbox.
+func (r *ProxyStore[K]) notifyWrite(ctx context.Context, topic string, before, after K) error {
+	if r.outboxed {
+		return nil
+	}
+
+	return r.notify(ctx, topic, before, after)
+}
+
+func (r *ProxyStore[K]) publish(ctx context.Context, topic string, c notifier.ChangeEvent[K]) error {
+	e := c.New
+	if topic == Deleted || topic == Unloaded {
+		e = c.Old
+	}
+
+	if err := r.events.Notify(ctx, topic, e); err != nil {
+		return err
+	}
+
+	return r.changeEvents.Notify(ctx, topic, c)
+}
+
+func (r *ProxyStore[K]) changed(a, b K) (bool, error) {
+	fa, err := r.fingerprint(a)
+	if err != nil {
+		return false, fmt.Errorf("could not fingerprint entity %s: %w", a.GetID(), err)
+	}
+	fb, err := r.fingerprint(b)
+	if err != nil {
+		return false, fmt.Errorf("could not fingerprint entity %s: %w", b.GetID(), err)
+	}
+
+	return fa != fb, nil
+}
+
+func find[K entity.Entity](entities []K, id entity.ID) (e K, ok bool) {
+	if id.IsEmpty() {
+		return
+	}
+
+	for _, e = range entities {
+		if e.GetID().Equals(id) {
+			return e, true
+		}
+	}
+
+	return *new(K), false
+}
@@ -0,0 +1,124 @@
+package store_test
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"slices"
+	"sync"
+	"testing"
+
+	"github.com/davfer/crudo/inmemory"
+	"github.com/davfer/crudo/store"
+)
+
+func TestProxyStore_ConcurrentLoad(t *testing.T) {
+	ctx := context.TODO()
+	s := store.NewProxyStore[*testProxyEntity]()
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 10)
+	for range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- s.Load(ctx, inmemory.NewRepository([]*testProxyEntity{{Id: "1"}}))
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	loaded := 0
+	for err := range errs {
+		if err == nil {
+			loaded++
+		}
+	}
+	if loaded != 1 {
+		t.Errorf("Load() succeeded %d times, want 1", loaded)
+	}
+}
+
+func TestProxyStore_ConcurrentUse(t *testing.T) {
+	ctx := context.TODO()
+	remote := inmemory.NewRepository([]*testProxyEntity{}, inmemory.WithIsolation[*testProxyEntity]())
+	s := store.NewProxyStore[*testProxyEntity]()
+	s.RefreshPolicy = store.RefreshPolicyReadAll
+	if err := s.Load(ctx, remote); err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 100)
+	for w := range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range 50 {
+				e, err := s.Create(ctx, &testProxyEntity{Attr1: fmt.Sprintf("w%d-%d", w, i)})
+				if err != nil {
+					errs <- err
+					return
+				}
+				if err = s.Update(ctx, &testProxyEntity{Id: e.Id, Attr1: e.Attr1 + "-updated"}); err != nil {
+					errs <- err
+					return
+				}
+				if i%2 == 0 {
+					if err = s.Delete(ctx, e); err != nil {
+						errs <- err
+						return
+					}
+				}
+				if _, err = s.ReadAll(ctx); err != nil {
+					errs <- err
+					return
+				}
+			}
+		}()
+	}
+	done := make(chan struct{})
+	refreshed := make(chan struct{})
+	go func() {
+		defer close(refreshed)
+		for {
+			select {
+			case <-done:
+				return
+			default:
+			}
+			if err := s.Refresh(ctx); err != nil {
+				errs <- err
+				return
+			}
+		}
+	}()
+	wg.Wait()
+	close(done)
+	<-refreshed
+	close(errs)
+
+	for err := range errs {
+		t.Errorf("concurrent use error = %v", err)
+	}
+
+	// no write may be lost or undone by a refresh running meanwhile
+	snapshot := func(r interface {
+		ReadAll(context.Context) ([]*testProxyEntity, error)
+	}) []string {
+		all, _ := r.ReadAll(ctx)
+		var got []string
+		for _, e := range all {
+			got = append(got, e.Id+":"+e.Attr1)
+		}
+		slices.Sort(got)
+		return got
+	}
+	local, remoteGot := snapshot(s), snapshot(remote)
+	if len(remoteGot) != 200 {
+		t.Errorf("remote entities = %d, want 200", len(remoteGot))
+	}
+	if !reflect.DeepEqual(local, remoteGot) {
+		t.Errorf("local entities differ from remote ones, local %d remote %d", len(local), len(remoteGot))
+	}
+}
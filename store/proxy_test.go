@@ -2,12 +2,20 @@ package store_test
 
 import (
 	"context"
+	"errors"
 	"reflect"
+	"slices"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
+	"github.com/davfer/archit/patterns/opts"
 	"github.com/davfer/crudo"
 	"github.com/davfer/crudo/entity"
+	"github.com/davfer/crudo/inmemory"
 	"github.com/davfer/crudo/notifier"
+	"github.com/davfer/crudo/outbox"
 	"github.com/davfer/crudo/store"
 	"github.com/davfer/go-specification"
 )
@@ -284,11 +292,22 @@ func TestProxyStore_On(t *testing.T) {
 		wantErr bool
 	}
 	tests := []testCase[*testProxyEntity]{
-		// TODO: Add test cases.
+		{
+			name:    "Test On",
+			r:       *store.NewProxyStore[*testProxyEntity](),
+			args:    args[*testProxyEntity]{event: store.Added, observer: func(context.Context, *testProxyEntity) error { return nil }},
+			wantErr: false,
+		},
+		{
+			name:    "Test On unknown event",
+			r:       *store.NewProxyStore[*testProxyEntity](),
+			args:    args[*testProxyEntity]{event: "unknown", observer: func(context.Context, *testProxyEntity) error { return nil }},
+			wantErr: true,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			if err := tt.r.On(tt.args.event, tt.args.observer); (err != nil) != tt.wantErr {
+			if _, err := tt.r.On(tt.args.event, tt.args.observer); (err != nil) != tt.wantErr {
 				t.Errorf("On() error = %v, wantErr %v", err, tt.wantErr)
 			}
 		})
@@ -438,3 +457,653 @@ func TestProxyStore_Update(t *testing.T) {
 		})
 	}
 }
+
+func TestProxyStore_RefreshDiff(t *testing.T) {
+	tests := []struct {
+		name        string
+		policy      store.RefreshPolicy
+		fingerprint store.FingerprintFunc[*testProxyEntity]
+		wantLocal   string
+		wantRemote  string
+		wantEvents  []string
+	}{
+		{
+			name:       "Test read refresh replaces stale local copy",
+			policy:     store.RefreshPolicyReadAll,
+			wantLocal:  "remote",
+			wantRemote: "remote",
+			wantEvents: []string{"updated:local->remote"},
+		},
+		{
+			name:       "Test read write refresh prefers remote copy",
+			policy:     store.RefreshPolicyReadWriteAll,
+			wantLocal:  "remote",
+			wantRemote: "remote",
+			wantEvents: []string{"updated:local->remote"},
+		},
+		{
+			name:       "Test write refresh keeps remote changes",
+			policy:     store.RefreshPolicyWriteAll,
+			wantLocal:  "local",
+			wantRemote: "remote",
+		},
+		{
+			name:   "Test custom fingerprint ignores content",
+			policy: store.RefreshPolicyReadAll,
+			fingerprint: func(e *testProxyEntity) (string, error) {
+				return e.SomeNiceField, nil
+			},
+			wantLocal:  "local",
+			wantRemote: "remote",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.TODO()
+			remote := inmemory.NewRepository(
+				[]*testProxyEntity{{Id: "1", Attr1: "local", SomeNiceField: "v1"}},
+				inmemory.WithIsolation[*testProxyEntity](),
+			)
+
+			var o []opts.Opt[store.ProxyStore[*testProxyEntity]]
+			if tt.fingerprint != nil {
+				o = append(o, store.WithFingerprint(tt.fingerprint))
+			}
+			s := store.NewProxyStore(o...)
+			s.RefreshPolicy = tt.policy
+
+			var events []string
+			_, _ = s.OnChange(store.Updated, func(ctx context.Context, c notifier.ChangeEvent[*testProxyEntity]) error {
+				events = append(events, c.Type+":"+c.Old.Attr1+"->"+c.New.Attr1)
+				return nil
+			})
+			if err := s.Load(ctx, remote); err != nil {
+				t.Fatalf("Load() error = %v", err)
+			}
+
+			// changed remotely by someone else
+			_ = remote.Update(ctx, &testProxyEntity{Id: "1", Attr1: "remote", SomeNiceField: "v1"})
+
+			if err := s.Refresh(ctx); err != nil {
+				t.Fatalf("Refresh() error = %v", err)
+			}
+
+			local, _ := s.Read(ctx, "1")
+			if local.Attr1 != tt.wantLocal {
+				t.Errorf("Refresh() local = %v, want %v", local.Attr1, tt.wantLocal)
+			}
+			if r, _ := remote.Read(ctx, "1"); r.Attr1 != tt.wantRemote {
+				t.Errorf("Refresh() remote = %v, want %v", r.Attr1, tt.wantRemote)
+			}
+			if !reflect.DeepEqual(events, tt.wantEvents) {
+				t.Errorf("Refresh() events = %v, want %v", events, tt.wantEvents)
+			}
+		})
+	}
+}
+
+func TestProxyStore_Scope(t *testing.T) {
+	ctx := context.TODO()
+	tenant := specification.Attr{Name: "SomeNiceField", Value: "tenant-a", Comparison: specification.ComparisonEq}
+	remote := inmemory.NewRepository([]*testProxyEntity{
+		{Id: "1", Attr1: "one", SomeNiceField: "tenant-a"},
+		{Id: "2", Attr1: "two", SomeNiceField: "tenant-b"},
+		{Id: "3", Attr1: "three", SomeNiceField: "tenant-a"},
+	}, inmemory.WithIsolation[*testProxyEntity]())
+
+	s := store.NewProxyStore(store.WithScope[*testProxyEntity](tenant))
+	s.RefreshPolicy = store.RefreshPolicyReadAll
+	if err := s.Load(ctx, remote); err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	if all, _ := s.ReadAll(ctx); len(all) != 2 {
+		t.Errorf("ReadAll() got = %v, want 2 entities", all)
+	}
+	if _, err := s.Read(ctx, "2"); !errors.Is(err, entity.ErrEntityNotFound) {
+		t.Errorf("Read() out of scope error = %v, want %v", err, entity.ErrEntityNotFound)
+	}
+
+	_, _ = remote.Create(ctx, &testProxyEntity{Id: "4", SomeNiceField: "tenant-a"})
+	_, _ = remote.Create(ctx, &testProxyEntity{Id: "5", SomeNiceField: "tenant-b"})
+	_ = remote.Update(ctx, &testProxyEntity{Id: "3", Attr1: "three", SomeNiceField: "tenant-b"})
+	if err := s.Refresh(ctx); err != nil {
+		t.Fatalf("Refresh() error = %v", err)
+	}
+
+	var ids []string
+	all, _ := s.ReadAll(ctx)
+	for _, e := range all {
+		ids = append(ids, e.Id)
+	}
+	if !reflect.DeepEqual(ids, []string{"1", "4"}) {
+		t.Errorf("Refresh() local ids = %v, want [1 4]", ids)
+	}
+
+	if err := s.Update(ctx, &testProxyEntity{Id: "1", SomeNiceField: "tenant-b"}); err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+	if all, _ = s.ReadAll(ctx); len(all) != 1 {
+		t.Errorf("Update() out of scope kept locally = %v", all)
+	}
+}
+
+func TestProxyStore_Lazy(t *testing.T) {
+	ctx := context.TODO()
+	remote := inmemory.NewRepository([]*testProxyEntity{
+		{Id: "1", Attr1: "one"},
+		{Id: "2", Attr1: "two"},
+		{Id: "3", Attr1: "three"},
+	}, inmemory.WithIsolation[*testProxyEntity]())
+
+	var loaded []string
+	s := store.NewProxyStore(
+		store.WithLazyLoading[*testProxyEntity](),
+		store.WithLocalOptions(inmemory.WithPolicy[*testProxyEntity](inmemory.PolicyLeastRecentlyUsed[*testProxyEntity]{Capacity: 2})),
+	)
+	_, _ = s.On(store.Loaded, func(ctx context.Context, e *testProxyEntity) error {
+		loaded = append(loaded, e.Id)
+		return nil
+	})
+	if err := s.Load(ctx, remote); err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if len(loaded) != 0 {
+		t.Errorf("Load() loaded = %v, want none", loaded)
+	}
+
+	for _, id := range []entity.ID{"1", "2", "1", "3"} {
+		if _, err := s.Read(ctx, id); err != nil {
+			t.Fatalf("Read(%s) error = %v", id, err)
+		}
+	}
+	if !reflect.DeepEqual(loaded, []string{"1", "2", "3"}) {
+		t.Errorf("Read() loaded = %v, want [1 2 3]", loaded)
+	}
+
+	one := specification.Attr{Name: "Attr1", Value: "two", Comparison: specification.ComparisonEq}
+	if e, err := s.MatchOne(ctx, one); err != nil || e.Id != "2" {
+		t.Errorf("MatchOne() got = %v, error = %v", e, err)
+	}
+	if all, err := s.ReadAll(ctx); err != nil || len(all) != 3 {
+		t.Errorf("ReadAll() got = %v, error = %v, want 3 entities", all, err)
+	}
+	if !reflect.DeepEqual(loaded, []string{"1", "2", "3", "2", "1", "3"}) {
+		t.Errorf("ReadAll() loaded = %v, want evicted entities loaded again", loaded)
+	}
+}
+
+func TestProxyStore_RefreshHydratesChanged(t *testing.T) {
+	ctx := context.TODO()
+	remote := inmemory.NewRepository([]*testProxyEntity{
+		{Id: "1", Attr1: "one"},
+		{Id: "2", Attr1: "two"},
+	}, inmemory.WithIsolation[*testProxyEntity]())
+
+	var hydrated []string
+	s := store.NewProxyStore[*testProxyEntity]()
+	s.RefreshPolicy = store.RefreshPolicyReadAll
+	s.OnHydrate(func(ctx context.Context, e *testProxyEntity) (*testProxyEntity, error) {
+		hydrated = append(hydrated, e.Id)
+		// modified in place, the raw state is fingerprinted before
+		e.SomeNiceField = "hydrated"
+		return e, nil
+	})
+	if err := s.Load(ctx, remote); err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	hydrated = nil
+	if err := s.Refresh(ctx); err != nil {
+		t.Fatalf("Refresh() error = %v", err)
+	}
+	if len(hydrated) != 0 {
+		t.Errorf("Refresh() unchanged hydrated = %v, want none", hydrated)
+	}
+
+	_ = remote.Update(ctx, &testProxyEntity{Id: "2", Attr1: "changed"})
+	_, _ = remote.Create(ctx, &testProxyEntity{Id: "3", Attr1: "three"})
+	if err := s.Refresh(ctx); err != nil {
+		t.Fatalf("Refresh() error = %v", err)
+	}
+	if !reflect.DeepEqual(hydrated, []string{"2", "3"}) {
+		t.Errorf("Refresh() hydrated = %v, want [2 3]", hydrated)
+	}
+	if e, err := s.Read(ctx, "2"); err != nil || e.Attr1 != "changed" || e.SomeNiceField != "hydrated" {
+		t.Errorf("Read() got = %v, error = %v, want changed and hydrated", e, err)
+	}
+}
+
+// scanningRepository is an in-memory remote counting the reads of whole collections.
+type scanningRepository struct {
+	*inmemory.Repository[*testProxyEntity]
+	scans atomic.Int32
+}
+
+func (r *scanningRepository) ReadAll(ctx context.Context) ([]*testProxyEntity, error) {
+	r.scans.Add(1)
+	return r.Repository.ReadAll(ctx)
+}
+
+func (r *scanningRepository) Match(ctx context.Context, c specification.Criteria) ([]*testProxyEntity, error) {
+	r.scans.Add(1)
+	return r.Repository.Match(ctx, c)
+}
+
+func TestProxyStore_LazyRefresh(t *testing.T) {
+	ctx := context.TODO()
+	remote := &scanningRepository{Repository: inmemory.NewRepository([]*testProxyEntity{
+		{Id: "1", Attr1: "one"},
+		{Id: "2", Attr1: "two"},
+		{Id: "3", Attr1: "three"},
+	}, inmemory.WithIsolation[*testProxyEntity]())}
+
+	s := store.NewProxyStore(store.WithLazyLoading[*testProxyEntity]())
+	s.RefreshPolicy = store.RefreshPolicyReadAll
+	if err := s.Load(ctx, remote); err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	for _, id := range []entity.ID{"1", "2"} {
+		if _, err := s.Read(ctx, id); err != nil {
+			t.Fatalf("Read(%s) error = %v", id, err)
+		}
+	}
+
+	_ = remote.Update(ctx, &testProxyEntity{Id: "1", Attr1: "changed"})
+	_ = remote.Delete(ctx, &testProxyEntity{Id: "2"})
+	var unloaded []string
+	_, _ = s.On(store.Unloaded, func(ctx context.Context, e *testProxyEntity) error {
+		unloaded = append(unloaded, e.Id)
+		return nil
+	})
+	if err := s.Refresh(ctx); err != nil {
+		t.Fatalf("Refresh() error = %v", err)
+	}
+
+	if n := remote.scans.Load(); n != 0 {
+		t.Errorf("Refresh() read the remote collection %d times, want only the cached entities", n)
+	}
+	if e, err := s.Read(ctx, "1"); err != nil || e.Attr1 != "changed" {
+		t.Errorf("Read() got = %v, error = %v, want changed", e, err)
+	}
+	if !reflect.DeepEqual(unloaded, []string{"2"}) {
+		t.Errorf("Refresh() unloaded = %v, want [2]", unloaded)
+	}
+}
+
+func TestProxyStore_Hydrate(t *testing.T) {
+	ctx := context.TODO()
+	remote := inmemory.NewRepository([]*testProxyEntity{
+		{Id: "1", Attr1: "one"},
+		{Id: "2", Attr1: "two"},
+		{Id: "3", Attr1: "three"},
+	}, inmemory.WithIsolation[*testProxyEntity]())
+
+	var running, peak atomic.Int32
+	s := store.NewProxyStore(store.WithHydrateConcurrency[*testProxyEntity](2))
+	s.RefreshPolicy = store.RefreshPolicyReadAll
+	s.OnHydrate(func(ctx context.Context, e *testProxyEntity) (*testProxyEntity, error) {
+		n := running.Add(1)
+		defer running.Add(-1)
+		if n > peak.Load() {
+			peak.Store(n)
+		}
+		time.Sleep(5 * time.Millisecond)
+		return &testProxyEntity{Id: e.Id, Attr1: e.Attr1, SomeNiceField: "hydrated"}, nil
+	})
+	var updated []string
+	_, _ = s.On(store.Updated, func(ctx context.Context, e *testProxyEntity) error {
+		updated = append(updated, e.Id)
+		return nil
+	})
+	if err := s.Load(ctx, remote); err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if n := peak.Load(); n > 2 {
+		t.Errorf("Load() concurrent hydrations = %d, want at most 2", n)
+	}
+
+	_, _ = remote.Create(ctx, &testProxyEntity{Id: "4", Attr1: "four"})
+	if err := s.Refresh(ctx); err != nil {
+		t.Fatalf("Refresh() error = %v", err)
+	}
+	if len(updated) != 0 {
+		t.Errorf("Refresh() updated = %v, want none", updated)
+	}
+
+	all, _ := s.ReadAll(ctx)
+	if len(all) != 4 {
+		t.Fatalf("ReadAll() got = %v, want 4 entities", all)
+	}
+	for _, e := range all {
+		if e.SomeNiceField != "hydrated" {
+			t.Errorf("ReadAll() entity %s not hydrated", e.Id)
+		}
+	}
+}
+
+// ownedEntity has an owner filled in by hydration only, not carried by JSON.
+type ownedEntity struct {
+	Id    string
+	Name  string
+	owner string
+}
+
+func (o *ownedEntity) GetID() entity.ID {
+	return entity.ID(o.Id)
+}
+
+func (o *ownedEntity) SetID(id entity.ID) error {
+	o.Id = string(id)
+	return nil
+}
+
+func (o *ownedEntity) GetResourceID() (string, error) {
+	return o.Name, nil
+}
+
+func (o *ownedEntity) SetResourceID(s string) error {
+	o.Name = s
+	return nil
+}
+
+func TestProxyStore_HydrateUnexported(t *testing.T) {
+	ctx := context.TODO()
+	s := store.NewProxyStore[*ownedEntity]()
+	s.OnHydrate(func(ctx context.Context, e *ownedEntity) (*ownedEntity, error) {
+		e.owner = "alice:" + e.Name
+		return e, nil
+	})
+	var old []string
+	_, _ = s.OnChange(store.Updated, func(ctx context.Context, c notifier.ChangeEvent[*ownedEntity]) error {
+		old = append(old, c.Old.Name)
+		return nil
+	})
+	if err := s.Load(ctx, inmemory.NewRepository([]*ownedEntity{{Id: "1", Name: "one"}}, inmemory.WithIsolation[*ownedEntity]())); err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	if e, err := s.Read(ctx, "1"); err != nil || e.owner != "alice:one" {
+		t.Errorf("Read() after Load = %+v, error = %v, want owner alice:one", e, err)
+	}
+	created, err := s.Create(ctx, &ownedEntity{Name: "two"})
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	if e, err := s.Read(ctx, created.GetID()); err != nil || e.owner != "alice:two" {
+		t.Errorf("Read() after Create = %+v, error = %v, want owner alice:two", e, err)
+	}
+	if err = s.Update(ctx, &ownedEntity{Id: "1", Name: "uno", owner: "bob"}); err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+	if !reflect.DeepEqual(old, []string{"one"}) {
+		t.Errorf("Update() previous names = %v, want [one]", old)
+	}
+	if e, _ := s.Read(ctx, "1"); e.owner != "bob" {
+		t.Errorf("Read() after Update owner = %s, want bob", e.owner)
+	}
+}
+
+func TestProxyStore_LocalRepository(t *testing.T) {
+	ctx := context.TODO()
+	dir := t.TempDir()
+	disk := func() *inmemory.Repository[*testProxyEntity] {
+		r := inmemory.NewRepository[*testProxyEntity](nil, inmemory.WithPersistence(inmemory.NewPersistence[*testProxyEntity](dir)))
+		if err := r.Start(ctx, nil); err != nil {
+			t.Fatalf("Start() error = %v", err)
+		}
+		return r
+	}
+	remote := inmemory.NewRepository([]*testProxyEntity{{Id: "1", Attr1: "one"}, {Id: "2", Attr1: "two"}})
+
+	// a previous run left a stale and an outdated entity on disk
+	local := disk()
+	_, _ = local.Create(ctx, &testProxyEntity{Id: "1", Attr1: "outdated"})
+	_, _ = local.Create(ctx, &testProxyEntity{Id: "9", Attr1: "stale"})
+
+	// memory -> disk -> remote
+	tier := store.NewProxyStore(store.WithLocalRepository[*testProxyEntity](local))
+	if err := tier.Load(ctx, remote); err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	s := store.NewProxyStore[*testProxyEntity]()
+	if err := s.Load(ctx, tier); err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if _, err := s.Create(ctx, &testProxyEntity{Attr1: "three"}); err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	if err := local.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	for name, r := range map[string]crudo.Repository[*testProxyEntity]{"disk": disk(), "remote": remote, "memory": s} {
+		var got []string
+		all, _ := r.ReadAll(ctx)
+		for _, e := range all {
+			got = append(got, e.Attr1)
+		}
+		slices.Sort(got)
+		if !reflect.DeepEqual(got, []string{"one", "three", "two"}) {
+			t.Errorf("%s entities = %v, want [one three two]", name, got)
+		}
+	}
+}
+
+func TestProxyStore_AsyncNotify(t *testing.T) {
+	ctx := context.TODO()
+	s := store.NewProxyStore(store.WithAsyncNotify[*testProxyEntity](2, 10, notifier.BackpressureBlock))
+	if err := s.Load(ctx, inmemory.NewRepository([]*testProxyEntity{})); err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	release := make(chan struct{})
+	var added atomic.Int32
+	_, _ = s.On(store.Added, func(ctx context.Context, e *testProxyEntity) error {
+		<-release
+		added.Add(1)
+		return nil
+	})
+
+	// a slow observer does not stall the writes
+	for range 3 {
+		if _, err := s.Create(ctx, &testProxyEntity{Attr1: "attr"}); err != nil {
+			t.Fatalf("Create() error = %v", err)
+		}
+	}
+	if n := added.Load(); n != 0 {
+		t.Errorf("Create() waited for %d observers", n)
+	}
+
+	close(release)
+	if err := s.Close(ctx); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	if n := added.Load(); n != 3 {
+		t.Errorf("Close() drained %d events, want 3", n)
+	}
+}
+
+func TestProxyStore_AsyncNotifyOrder(t *testing.T) {
+	ctx := context.TODO()
+	s := store.NewProxyStore(store.WithAsyncNotify[*testProxyEntity](4, 10, notifier.BackpressureBlock))
+	if err := s.Load(ctx, inmemory.NewRepository([]*testProxyEntity{})); err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	var lock sync.Mutex
+	var got []string
+	record := func(ctx context.Context, e *testProxyEntity) error {
+		topic, _ := notifier.TopicFromContext(ctx)
+		lock.Lock()
+		defer lock.Unlock()
+		got = append(got, topic)
+		return nil
+	}
+	_, _ = s.On(store.Added, func(ctx context.Context, e *testProxyEntity) error {
+		time.Sleep(20 * time.Millisecond)
+		return record(ctx, e)
+	})
+	_, _ = s.On(store.Deleted, record)
+
+	// the events of an entity keep their order across topics
+	e, _ := s.Create(ctx, &testProxyEntity{Attr1: "attr"})
+	_ = s.Delete(ctx, e)
+	if err := s.Close(ctx); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	if !reflect.DeepEqual(got, []string{store.Added, store.Deleted}) {
+		t.Errorf("notified = %v, want [added deleted]", got)
+	}
+}
+
+func TestProxyStore_OnWildcard(t *testing.T) {
+	ctx := context.TODO()
+	s := store.NewProxyStore[*testProxyEntity]()
+	if err := s.Load(ctx, inmemory.NewRepository([]*testProxyEntity{})); err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	var got []string
+	if _, err := s.On("*", func(ctx context.Context, e *testProxyEntity) error {
+		topic, _ := notifier.TopicFromContext(ctx)
+		got = append(got, topic)
+		return nil
+	}); err != nil {
+		t.Fatalf("On() error = %v", err)
+	}
+
+	e, _ := s.Create(ctx, &testProxyEntity{Attr1: "one"})
+	_ = s.Update(ctx, e)
+	_ = s.Delete(ctx, e)
+	if !reflect.DeepEqual(got, []string{store.Added, store.Updated, store.Deleted}) {
+		t.Errorf("On() got topics = %v", got)
+	}
+}
+
+func TestProxyStore_OnFilter(t *testing.T) {
+	ctx := context.TODO()
+	tenant := specification.Attr{Name: "SomeNiceField", Value: "tenant-a", Comparison: specification.ComparisonEq}
+	s := store.NewProxyStore[*testProxyEntity]()
+	if err := s.Load(ctx, inmemory.NewRepository([]*testProxyEntity{}, inmemory.WithIsolation[*testProxyEntity]())); err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	var added, changed []string
+	_, _ = s.On(store.Added, func(ctx context.Context, e *testProxyEntity) error {
+		added = append(added, e.Attr1)
+		return nil
+	}, tenant)
+	_, _ = s.OnChange(store.Updated, func(ctx context.Context, c notifier.ChangeEvent[*testProxyEntity]) error {
+		changed = append(changed, c.New.Attr1)
+		return nil
+	}, tenant)
+
+	a, _ := s.Create(ctx, &testProxyEntity{Attr1: "a", SomeNiceField: "tenant-a"})
+	b, _ := s.Create(ctx, &testProxyEntity{Attr1: "b", SomeNiceField: "tenant-b"})
+	_ = s.Update(ctx, &testProxyEntity{Id: b.Id, Attr1: "b2", SomeNiceField: "tenant-b"})
+	// leaving the tenant is still delivered to change observers
+	_ = s.Update(ctx, &testProxyEntity{Id: a.Id, Attr1: "a2", SomeNiceField: "tenant-b"})
+
+	if !reflect.DeepEqual(added, []string{"a"}) {
+		t.Errorf("On() got = %v, want [a]", added)
+	}
+	if !reflect.DeepEqual(changed, []string{"a2"}) {
+		t.Errorf("OnChange() got = %v, want [a2]", changed)
+	}
+}
+
+func TestProxyStore_ApplyChange(t *testing.T) {
+	ctx := context.TODO()
+	tenant := specification.Attr{Name: "SomeNiceField", Value: "tenant-a", Comparison: specification.ComparisonEq}
+	s := store.NewProxyStore(store.WithScope[*testProxyEntity](tenant))
+	if err := s.Load(ctx, inmemory.NewRepository([]*testProxyEntity{
+		{Id: "1", Attr1: "one", SomeNiceField: "tenant-a"},
+	}, inmemory.WithIsolation[*testProxyEntity]())); err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	var got []string
+	_, _ = s.On("*", func(ctx context.Context, e *testProxyEntity) error {
+		topic, _ := notifier.TopicFromContext(ctx)
+		got = append(got, topic+":"+e.Id)
+		return nil
+	})
+
+	// changes made by others, e.g. published by a mongo Watch
+	var remote notifier.Publisher[notifier.ChangeEvent[*testProxyEntity]] = notifier.PublisherFunc[notifier.ChangeEvent[*testProxyEntity]](s.ApplyChange)
+	changes := []struct {
+		topic         string
+		before, after *testProxyEntity
+	}{
+		{store.Added, nil, &testProxyEntity{Id: "2", Attr1: "two", SomeNiceField: "tenant-a"}},
+		{store.Added, nil, &testProxyEntity{Id: "3", Attr1: "three", SomeNiceField: "tenant-b"}},
+		{store.Updated, nil, &testProxyEntity{Id: "1", Attr1: "one", SomeNiceField: "tenant-a"}},
+		{store.Updated, nil, &testProxyEntity{Id: "2", Attr1: "two2", SomeNiceField: "tenant-a"}},
+		{store.Updated, nil, &testProxyEntity{Id: "1", Attr1: "one", SomeNiceField: "tenant-b"}},
+		{store.Deleted, &testProxyEntity{Id: "2"}, nil},
+		{store.Deleted, &testProxyEntity{Id: "3"}, nil},
+	}
+	for _, c := range changes {
+		if err := remote.Notify(ctx, c.topic, notifier.NewChangeEvent(ctx, c.topic, c.before, c.after, 0)); err != nil {
+			t.Fatalf("ApplyChange() error = %v", err)
+		}
+	}
+
+	want := []string{"loaded:2", "updated:2", "unloaded:1", "unloaded:2"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ApplyChange() notified = %v, want %v", got, want)
+	}
+	if all, _ := s.ReadAll(ctx); len(all) != 0 {
+		t.Errorf("ApplyChange() local = %v, want none", all)
+	}
+}
+
+func TestProxyStore_Outbox(t *testing.T) {
+	ctx := context.TODO()
+	records := outbox.NewMemoryStore()
+	s := store.NewProxyStore(store.WithOutbox[*testProxyEntity]())
+	remote := outbox.NewRepository[*testProxyEntity](inmemory.NewRepository([]*testProxyEntity{}, inmemory.WithIsolation[*testProxyEntity]()), records)
+	if err := s.Load(ctx, remote); err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	var got []string
+	_, _ = s.On("*", func(ctx context.Context, e *testProxyEntity) error {
+		topic, _ := notifier.TopicFromContext(ctx)
+		got = append(got, topic+":"+e.Attr1)
+		return nil
+	})
+	var sequences []uint64
+	_, _ = s.OnChange("*", func(ctx context.Context, c notifier.ChangeEvent[*testProxyEntity]) error {
+		sequences = append(sequences, c.Sequence)
+		return nil
+	})
+
+	e, err := s.Create(ctx, &testProxyEntity{Attr1: "one"})
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	if err = s.Update(ctx, &testProxyEntity{Id: e.Id, Attr1: "two"}); err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+	if err = s.Delete(ctx, &testProxyEntity{Id: e.Id, Attr1: "two"}); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	if len(got) != 0 {
+		t.Fatalf("notified = %v before relaying, want none", got)
+	}
+
+	relay := outbox.NewRelay[*testProxyEntity](records, notifier.PublisherFunc[notifier.ChangeEvent[*testProxyEntity]](s.NotifyChange))
+	if published, err := relay.Relay(ctx); err != nil || published != 3 {
+		t.Fatalf("Relay() published = %d, error = %v, want 3", published, err)
+	}
+
+	if want := []string{"added:one", "updated:two", "deleted:two"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("notified = %v, want %v", got, want)
+	}
+	if want := []uint64{1, 2, 3}; !reflect.DeepEqual(sequences, want) {
+		t.Errorf("sequences = %v, want %v", sequences, want)
+	}
+}
@@ -0,0 +1,127 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/davfer/crudo/entity"
+)
+
+type ReadStats struct {
+	Hits         uint64 // Hits served by the local repository
+	Misses       uint64 // Misses read from the remote repository
+	Coalesced    uint64 // Coalesced misses that waited for a concurrent remote read of the same entity
+	NegativeHits uint64 // NegativeHits answered as not found by the negative cache
+}
+
+type flight[K entity.Entity] struct {
+	done      chan struct{}
+	e         K
+	err       error
+	cancelled bool // cancelled is set when the read failed as the context of its caller was done
+	stale     bool // stale is set when the entity was written during the read, its not found is not remembered
+}
+
+// readGroup coalesces concurrent remote reads of the same entity and remembers recent not found ones for NegativeTTL.
+type readGroup[K entity.Entity] struct {
+	NegativeTTL time.Duration
+	lock        *sync.Mutex
+	flights     map[entity.ID]*flight[K]
+	notFound    map[entity.ID]time.Time
+	stats       ReadStats
+}
+
+func newReadGroup[K entity.Entity](negativeTTL time.Duration) *readGroup[K] {
+	return &readGroup[K]{
+		NegativeTTL: negativeTTL,
+		lock:        &sync.Mutex{},
+		flights:     map[entity.ID]*flight[K]{},
+		notFound:    map[entity.ID]time.Time{},
+	}
+}
+
+func (g *readGroup[K]) hit() {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+
+	g.stats.Hits++
+}
+
+// do runs read once for all the concurrent callers missing the same entity. Callers still waiting when the read is
+// cancelled by the context of the one running it read again.
+func (g *readGroup[K]) do(ctx context.Context, id entity.ID, read func() (K, error)) (K, error) {
+	g.lock.Lock()
+	if expires, ok := g.notFound[id]; ok && time.Now().Before(expires) {
+		g.stats.NegativeHits++
+		g.lock.Unlock()
+		return *new(K), entity.ErrEntityNotFound
+	}
+	if f, ok := g.flights[id]; ok {
+		g.stats.Coalesced++
+		g.lock.Unlock()
+
+		select {
+		case <-f.done:
+			if f.cancelled && ctx.Err() == nil {
+				return g.do(ctx, id, read)
+			}
+			return f.e, f.err
+		case <-ctx.Done():
+			return *new(K), ctx.Err()
+		}
+	}
+
+	f := &flight[K]{done: make(chan struct{})}
+	g.flights[id] = f
+	g.stats.Misses++
+	g.lock.Unlock()
+
+	f.e, f.err = read()
+	f.cancelled = f.err != nil && ctx.Err() != nil
+
+	g.lock.Lock()
+	if g.flights[id] == f {
+		delete(g.flights, id)
+	}
+	if g.NegativeTTL > 0 && !f.stale && errors.Is(f.err, entity.ErrEntityNotFound) {
+		g.remember(id)
+	}
+	g.lock.Unlock()
+	close(f.done)
+
+	return f.e, f.err
+}
+
+// remember caches id as not found, dropping the expired entries. Callers must hold the lock.
+func (g *readGroup[K]) remember(id entity.ID) {
+	now := time.Now()
+	for i, expires := range g.notFound {
+		if !now.Before(expires) {
+			delete(g.notFound, i)
+		}
+	}
+
+	g.notFound[id] = now.Add(g.NegativeTTL)
+}
+
+// forget drops id from the negative cache, e.g. once it is created. A read of id in flight may have missed it, so it is
+// not remembered and later reads do not wait for it.
+func (g *readGroup[K]) forget(id entity.ID) {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+
+	delete(g.notFound, id)
+	if f, ok := g.flights[id]; ok {
+		f.stale = true
+		delete(g.flights, id)
+	}
+}
+
+func (g *readGroup[K]) Stats() ReadStats {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+
+	return g.stats
+}
@@ -0,0 +1,193 @@
+package store_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/davfer/crudo/entity"
+	"github.com/davfer/crudo/inmemory"
+	"github.com/davfer/crudo/notifier"
+	"github.com/davfer/crudo/store"
+)
+
+// slowRepository is an in-memory remote counting its reads and holding their results until release is closed, so a
+// held read misses the writes made meanwhile.
+type slowRepository struct {
+	*inmemory.Repository[*testProxyEntity]
+	reads   atomic.Int32
+	release chan struct{}
+}
+
+func (r *slowRepository) Read(ctx context.Context, id entity.ID) (*testProxyEntity, error) {
+	r.reads.Add(1)
+	e, err := r.Repository.Read(ctx, id)
+	select {
+	case <-r.release:
+		return e, err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func TestProxyStore_ReadCoalescing(t *testing.T) {
+	ctx := context.TODO()
+	remote := &slowRepository{
+		Repository: inmemory.NewRepository([]*testProxyEntity{{Id: "1", Attr1: "one"}}),
+		release:    make(chan struct{}),
+	}
+	s := store.NewProxyStore(store.WithLazyLoading[*testProxyEntity]())
+	if err := s.Load(ctx, remote); err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 10)
+	for range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			e, err := s.Read(ctx, "1")
+			if err == nil && e.Attr1 != "one" {
+				err = errors.New("unexpected entity " + e.Attr1)
+			}
+			errs <- err
+		}()
+	}
+	// let every reader reach the remote read before releasing it
+	for s.ReadStats().Misses+s.ReadStats().Coalesced < 10 {
+		time.Sleep(time.Millisecond)
+	}
+	close(remote.release)
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			t.Errorf("Read() error = %v", err)
+		}
+	}
+	if n := remote.reads.Load(); n != 1 {
+		t.Errorf("Read() remote reads = %d, want 1", n)
+	}
+	if _, err := s.Read(ctx, "1"); err != nil {
+		t.Fatalf("Read() error = %v", err)
+	}
+	if got := s.ReadStats(); got != (store.ReadStats{Hits: 1, Misses: 1, Coalesced: 9}) {
+		t.Errorf("ReadStats() got = %+v", got)
+	}
+}
+
+func TestProxyStore_NegativeCache(t *testing.T) {
+	ctx := context.TODO()
+	remote := &slowRepository{
+		Repository: inmemory.NewRepository([]*testProxyEntity{}),
+		release:    make(chan struct{}),
+	}
+	close(remote.release)
+	s := store.NewProxyStore(store.WithNegativeCache[*testProxyEntity](50 * time.Millisecond))
+	if err := s.Load(ctx, remote); err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	for range 3 {
+		if _, err := s.Read(ctx, "missing"); !errors.Is(err, entity.ErrEntityNotFound) {
+			t.Fatalf("Read() error = %v, want %v", err, entity.ErrEntityNotFound)
+		}
+	}
+	if n := remote.reads.Load(); n != 1 {
+		t.Errorf("Read() remote reads = %d, want 1", n)
+	}
+
+	time.Sleep(60 * time.Millisecond)
+	_, _ = s.Read(ctx, "missing")
+	if n := remote.reads.Load(); n != 2 {
+		t.Errorf("Read() remote reads after ttl = %d, want 2", n)
+	}
+	if got := s.ReadStats(); got != (store.ReadStats{Misses: 2, NegativeHits: 2}) {
+		t.Errorf("ReadStats() got = %+v", got)
+	}
+}
+
+func TestProxyStore_NegativeCacheWrittenDuringRead(t *testing.T) {
+	ctx := context.TODO()
+	remote := &slowRepository{
+		Repository: inmemory.NewRepository([]*testProxyEntity{}),
+		release:    make(chan struct{}),
+	}
+	s := store.NewProxyStore(
+		store.WithLazyLoading[*testProxyEntity](),
+		store.WithNegativeCache[*testProxyEntity](time.Minute),
+	)
+	if err := s.Load(ctx, remote); err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	read := make(chan error, 1)
+	go func() {
+		_, err := s.Read(ctx, "1")
+		read <- err
+	}()
+	for remote.reads.Load() < 1 {
+		time.Sleep(time.Millisecond)
+	}
+	// created remotely while the read is held
+	e, _ := remote.Repository.Create(ctx, &testProxyEntity{Id: "1", Attr1: "one"})
+	if err := s.ApplyChange(ctx, store.Added, notifier.ChangeEvent[*testProxyEntity]{ID: "1", New: e}); err != nil {
+		t.Fatalf("ApplyChange() error = %v", err)
+	}
+	close(remote.release)
+	if err := <-read; !errors.Is(err, entity.ErrEntityNotFound) {
+		t.Fatalf("Read() held error = %v, want %v", err, entity.ErrEntityNotFound)
+	}
+
+	if e, err := s.Read(ctx, "1"); err != nil || e.Attr1 != "one" {
+		t.Errorf("Read() got = %v, error = %v, want the created entity", e, err)
+	}
+}
+
+func TestProxyStore_ReadCoalescingCancelled(t *testing.T) {
+	ctx := context.TODO()
+	remote := &slowRepository{
+		Repository: inmemory.NewRepository([]*testProxyEntity{{Id: "1", Attr1: "one"}}),
+		release:    make(chan struct{}),
+	}
+	s := store.NewProxyStore(store.WithLazyLoading[*testProxyEntity]())
+	if err := s.Load(ctx, remote); err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	leaderCtx, cancel := context.WithCancel(ctx)
+	leader := make(chan error, 1)
+	go func() {
+		_, err := s.Read(leaderCtx, "1")
+		leader <- err
+	}()
+	for remote.reads.Load() < 1 {
+		time.Sleep(time.Millisecond)
+	}
+	type result struct {
+		e   *testProxyEntity
+		err error
+	}
+	follower := make(chan result, 1)
+	go func() {
+		e, err := s.Read(ctx, "1")
+		follower <- result{e, err}
+	}()
+	for s.ReadStats().Coalesced < 1 {
+		time.Sleep(time.Millisecond)
+	}
+
+	cancel()
+	if err := <-leader; !errors.Is(err, context.Canceled) {
+		t.Fatalf("Read() leader error = %v, want %v", err, context.Canceled)
+	}
+	close(remote.release)
+	if got := <-follower; got.err != nil || got.e.Attr1 != "one" {
+		t.Errorf("Read() follower got = %v, error = %v, want the entity", got.e, got.err)
+	}
+}
@@ -0,0 +1,184 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"math/rand/v2"
+	"sync"
+	"time"
+
+	"github.com/davfer/archit/patterns/opts"
+	"github.com/go-logr/logr"
+)
+
+type Refreshable interface {
+	Refresh(context.Context) error
+}
+
+// Refresher keeps a store warm by refreshing it periodically in the background. Refreshes never overlap, remote
+// errors back off exponentially up to MaxBackoff and every delay is spread by a random Jitter.
+type Refresher struct {
+	Interval   time.Duration
+	Jitter     time.Duration
+	MaxBackoff time.Duration
+	store      Refreshable
+	logger     logr.Logger
+	refreshing *sync.Mutex
+	lock       *sync.Mutex
+	lastRun    time.Time
+	lastErr    error
+	failures   int
+	cancel     context.CancelFunc
+	done       chan struct{}
+}
+
+func NewRefresher(s Refreshable, interval time.Duration, o ...opts.Opt[Refresher]) *Refresher {
+	r := opts.New[Refresher](o...)
+
+	r.store = s
+	r.Interval = interval
+	if r.MaxBackoff < interval {
+		r.MaxBackoff = 10 * interval
+	}
+	if r.logger.GetSink() == nil {
+		r.logger = logr.Discard()
+	}
+	r.refreshing = &sync.Mutex{}
+	r.lock = &sync.Mutex{}
+
+	return &r
+}
+
+func WithJitter(jitter time.Duration) opts.Opt[Refresher] {
+	return func(r Refresher) Refresher {
+		r.Jitter = jitter
+		return r
+	}
+}
+
+func WithMaxBackoff(max time.Duration) opts.Opt[Refresher] {
+	return func(r Refresher) Refresher {
+		r.MaxBackoff = max
+		return r
+	}
+}
+
+func WithRefresherLogger(logger logr.Logger) opts.Opt[Refresher] {
+	return func(r Refresher) Refresher {
+		r.logger = logger
+		return r
+	}
+}
+
+// Start runs the refresh loop until ctx is done or Close is called.
+func (r *Refresher) Start(ctx context.Context) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if r.done != nil {
+		return fmt.Errorf("refresher already started")
+	}
+	if r.Interval <= 0 {
+		return fmt.Errorf("refresher interval must be positive")
+	}
+
+	ctx, r.cancel = context.WithCancel(ctx)
+	r.done = make(chan struct{})
+	go r.run(ctx, r.done)
+
+	return nil
+}
+
+// Close stops the refresh loop and waits for a running refresh to finish.
+func (r *Refresher) Close() error {
+	r.lock.Lock()
+	cancel, done := r.cancel, r.done
+	r.cancel, r.done = nil, nil
+	r.lock.Unlock()
+
+	if cancel == nil {
+		return nil
+	}
+
+	cancel()
+	<-done
+
+	return nil
+}
+
+// Refresh refreshes the store right away, waiting for an ongoing refresh to finish first.
+func (r *Refresher) Refresh(ctx context.Context) error {
+	r.refreshing.Lock()
+	defer r.refreshing.Unlock()
+
+	err := r.store.Refresh(ctx)
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.lastErr = err
+	if err != nil {
+		r.failures++
+		return err
+	}
+
+	r.failures = 0
+	r.lastRun = time.Now()
+	return nil
+}
+
+// LastRefreshed returns when the last successful refresh finished.
+func (r *Refresher) LastRefreshed() time.Time {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	return r.lastRun
+}
+
+// Err returns the error of the last refresh, nil if it succeeded.
+func (r *Refresher) Err() error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	return r.lastErr
+}
+
+func (r *Refresher) run(ctx context.Context, done chan struct{}) {
+	defer close(done)
+
+	timer := time.NewTimer(r.delay())
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-timer.C:
+		}
+
+		if err := r.Refresh(ctx); err != nil && ctx.Err() == nil {
+			r.logger.Error(err, "error refreshing store")
+		}
+		timer.Reset(r.delay())
+	}
+}
+
+// delay returns the wait before the next refresh, backing off exponentially after consecutive failures.
+func (r *Refresher) delay() time.Duration {
+	r.lock.Lock()
+	failures := r.failures
+	r.lock.Unlock()
+
+	d := r.Interval
+	for i := 0; i < failures && d < r.MaxBackoff; i++ {
+		d *= 2
+	}
+	if d > r.MaxBackoff {
+		d = r.MaxBackoff
+	}
+	if r.Jitter > 0 {
+		d += rand.N(r.Jitter)
+	}
+
+	return d
+}
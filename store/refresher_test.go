@@ -0,0 +1,111 @@
+package store_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/davfer/crudo/store"
+)
+
+type countingRefreshable struct {
+	calls    atomic.Int32
+	running  atomic.Int32
+	overlaps atomic.Int32
+	failing  atomic.Bool
+}
+
+func (c *countingRefreshable) Refresh(ctx context.Context) error {
+	if c.running.Add(1) > 1 {
+		c.overlaps.Add(1)
+	}
+	defer c.running.Add(-1)
+
+	c.calls.Add(1)
+	time.Sleep(time.Millisecond)
+	if c.failing.Load() {
+		return errors.New("remote down")
+	}
+	return nil
+}
+
+func TestRefresher_Start(t *testing.T) {
+	ctx := context.TODO()
+	s := &countingRefreshable{}
+	r := store.NewRefresher(s, 5*time.Millisecond, store.WithJitter(time.Millisecond))
+
+	if err := r.Start(ctx); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	if err := r.Start(ctx); err == nil {
+		t.Errorf("Start() expected error on double start")
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for s.calls.Load() < 3 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if err := r.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	calls := s.calls.Load()
+	if calls < 3 {
+		t.Errorf("Refresh() calls = %d, want at least 3", calls)
+	}
+	if r.LastRefreshed().IsZero() || r.Err() != nil {
+		t.Errorf("LastRefreshed() = %v, Err() = %v", r.LastRefreshed(), r.Err())
+	}
+
+	time.Sleep(20 * time.Millisecond)
+	if s.calls.Load() != calls {
+		t.Errorf("Refresh() called after Close()")
+	}
+}
+
+func TestRefresher_Backoff(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.TODO())
+	s := &countingRefreshable{}
+	s.failing.Store(true)
+	r := store.NewRefresher(s, 5*time.Millisecond, store.WithMaxBackoff(time.Hour))
+
+	if err := r.Start(ctx); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	time.Sleep(100 * time.Millisecond)
+	cancel()
+	_ = r.Close()
+
+	// 5ms, 10ms, 20ms, 40ms... only a handful of attempts fit in 100ms
+	if calls := s.calls.Load(); calls == 0 || calls > 5 {
+		t.Errorf("Refresh() calls = %d, want between 1 and 5", calls)
+	}
+	if r.Err() == nil || !r.LastRefreshed().IsZero() {
+		t.Errorf("Err() = %v, LastRefreshed() = %v", r.Err(), r.LastRefreshed())
+	}
+}
+
+func TestRefresher_NoOverlap(t *testing.T) {
+	ctx := context.TODO()
+	s := &countingRefreshable{}
+	r := store.NewRefresher(s, time.Millisecond)
+	_ = r.Start(ctx)
+	defer r.Close()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_ = r.Refresh(ctx)
+		}()
+	}
+	wg.Wait()
+
+	if overlaps := s.overlaps.Load(); overlaps != 0 {
+		t.Errorf("Refresh() overlaps = %d, want 0", overlaps)
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"github.com/davfer/crudo"
 	"github.com/davfer/crudo/entity"
 	"github.com/davfer/crudo/notifier"
+	"github.com/davfer/go-specification"
 )
 
 type Store[K entity.Entity] interface {
@@ -14,8 +15,14 @@ type Store[K entity.Entity] interface {
 	Load(context.Context, crudo.Repository[K]) error
 	// Refresh updates the store with the latest data from the repository
 	Refresh(context.Context) error
+	// ApplyChange applies a change of the repository made elsewhere, see notifier.PublisherFunc
+	ApplyChange(context.Context, string, notifier.ChangeEvent[K]) error
 	// OnHydrate sets the hydrate function to be called when an entity is loaded
 	OnHydrate(hydrateFunc HydrateFunc[K])
-	// On attaches an observer to the store
-	On(string, notifier.ObserverCallback[K]) error
+	// On attaches an observer to the store, it is detached by unsubscribing the returned subscription. Filters restrict
+	// the delivered entities to the ones satisfying all of them
+	On(string, notifier.ObserverCallback[K], ...specification.Criteria) (*notifier.Subscription, error)
+	// OnChange attaches an observer receiving the previous and the new state of the entity, filters are satisfied by
+	// either of them
+	OnChange(string, notifier.ObserverCallback[notifier.ChangeEvent[K]], ...specification.Criteria) (*notifier.Subscription, error)
 }
@@ -0,0 +1,235 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/go-logr/logr"
+
+	"github.com/davfer/crudo"
+	"github.com/davfer/crudo/entity"
+	"github.com/davfer/crudo/inmemory"
+)
+
+type WriteBehindStats struct {
+	Pending   int    // Pending writes waiting to be flushed
+	Flushed   uint64 // Flushed writes applied remotely
+	Coalesced uint64 // Coalesced writes merged into a pending one
+	Retried   uint64 // Retried writes that failed and were queued again
+	Dropped   uint64 // Dropped writes that exhausted their retries
+}
+
+type pendingWrite[K entity.Entity] struct {
+	op       string
+	e        K
+	attempts int
+	err      error
+}
+
+// writeQueue holds the pending remote writes of a write-behind store, coalesced per entity ID and flushed in order.
+type writeQueue[K entity.Entity] struct {
+	Interval  time.Duration
+	BatchSize int
+	Retries   int
+	ids       inmemory.IdStrategy[K]
+	lock      *sync.Mutex
+	flushing  *sync.Mutex
+	pending   map[entity.ID]*pendingWrite[K]
+	order     []entity.ID
+	stats     WriteBehindStats
+	logger    logr.Logger
+	onFlushed func(op string, e K)
+	cancel    context.CancelFunc
+	done      chan struct{}
+}
+
+func newWriteQueue[K entity.Entity](interval time.Duration, batchSize, retries int) *writeQueue[K] {
+	if interval <= 0 {
+		interval = time.Second
+	}
+	if batchSize <= 0 {
+		batchSize = 100
+	}
+
+	return &writeQueue[K]{
+		Interval:  interval,
+		BatchSize: batchSize,
+		Retries:   retries,
+		lock:      &sync.Mutex{},
+		flushing:  &sync.Mutex{},
+		pending:   map[entity.ID]*pendingWrite[K]{},
+		logger:    logr.Discard(),
+	}
+}
+
+func (q *writeQueue[K]) enqueue(op string, e K) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	q.push(&pendingWrite[K]{op: op, e: e})
+}
+
+// push queues w, merging it with the pending write of the same entity. Callers must hold the lock.
+func (q *writeQueue[K]) push(w *pendingWrite[K]) {
+	id := w.e.GetID()
+	prev, ok := q.pending[id]
+	if !ok {
+		q.pending[id] = w
+		q.order = append(q.order, id)
+		return
+	}
+
+	q.stats.Coalesced++
+	switch {
+	case prev.op == Added && w.op == Deleted:
+		// never reached the remote, nothing to do
+		delete(q.pending, id)
+		return
+	case prev.op == Added:
+		w.op = Added
+	case prev.op == Deleted && w.op == Added:
+		// the remote still holds the deleted entity
+		w.op = Updated
+	}
+	w.attempts = prev.attempts
+	q.pending[id] = w
+}
+
+// pendingOp returns the queued operation of the entity, if any.
+func (q *writeQueue[K]) pendingOp(id entity.ID) (string, bool) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	w, ok := q.pending[id]
+	if !ok {
+		return "", false
+	}
+
+	return w.op, true
+}
+
+func (q *writeQueue[K]) next() []*pendingWrite[K] {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	var batch []*pendingWrite[K]
+	for len(q.order) > 0 && len(batch) < q.BatchSize {
+		id := q.order[0]
+		q.order = q.order[1:]
+		if w, ok := q.pending[id]; ok {
+			delete(q.pending, id)
+			batch = append(batch, w)
+		}
+	}
+
+	return batch
+}
+
+// flush applies one round of pending writes to the remote repository, failed writes are queued again until they run
+// out of retries.
+func (q *writeQueue[K]) flush(ctx context.Context, remote crudo.Repository[K]) error {
+	q.flushing.Lock()
+	defer q.flushing.Unlock()
+
+	var errs []error
+	var failed []*pendingWrite[K]
+	rounds := q.Stats().Pending
+	for rounds > 0 {
+		batch := q.next()
+		if len(batch) == 0 {
+			break
+		}
+		rounds -= len(batch)
+
+		for _, w := range batch {
+			var err error
+			switch w.op {
+			case Added:
+				_, err = remote.Create(ctx, w.e)
+			case Updated:
+				err = remote.Update(ctx, w.e)
+			case Deleted:
+				err = remote.Delete(ctx, w.e)
+			}
+			if err != nil {
+				w.err = fmt.Errorf("could not flush %s entity %s: %w", w.op, w.e.GetID(), err)
+				errs = append(errs, w.err)
+				failed = append(failed, w)
+				continue
+			}
+
+			q.lock.Lock()
+			q.stats.Flushed++
+			q.lock.Unlock()
+			if q.onFlushed != nil {
+				q.onFlushed(w.op, w.e)
+			}
+		}
+	}
+
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	for _, w := range failed {
+		w.attempts++
+		if w.attempts > q.Retries {
+			q.stats.Dropped++
+			q.logger.Error(w.err, "dropping write after retries", "id", w.e.GetID(), "op", w.op)
+			continue
+		}
+
+		q.stats.Retried++
+		// a newer write of the same entity may have been queued meanwhile
+		newer, ok := q.pending[w.e.GetID()]
+		delete(q.pending, w.e.GetID())
+		q.push(w)
+		if ok {
+			q.push(newer)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+func (q *writeQueue[K]) start(ctx context.Context, remote crudo.Repository[K]) {
+	ctx, q.cancel = context.WithCancel(ctx)
+	q.done = make(chan struct{})
+
+	go func() {
+		defer close(q.done)
+
+		ticker := time.NewTicker(q.Interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := q.flush(ctx, remote); err != nil {
+					q.logger.Error(err, "error flushing writes")
+				}
+			}
+		}
+	}()
+}
+
+func (q *writeQueue[K]) stop() {
+	if q.cancel == nil {
+		return
+	}
+
+	q.cancel()
+	<-q.done
+	q.cancel = nil
+}
+
+func (q *writeQueue[K]) Stats() WriteBehindStats {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	stats := q.stats
+	stats.Pending = len(q.pending)
+	return stats
+}
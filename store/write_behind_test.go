@@ -0,0 +1,231 @@
+package store_test
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/davfer/crudo/entity"
+	"github.com/davfer/crudo/inmemory"
+	"github.com/davfer/crudo/mongo"
+	"github.com/davfer/crudo/store"
+)
+
+// recordingRepository is an in-memory remote recording the writes it receives and failing the first failures of them.
+type recordingRepository struct {
+	*inmemory.Repository[*testProxyEntity]
+	lock     sync.Mutex
+	writes   []string
+	failures int
+}
+
+func newRecordingRepository(c []*testProxyEntity) *recordingRepository {
+	return &recordingRepository{Repository: inmemory.NewRepository(c, inmemory.WithIsolation[*testProxyEntity]())}
+}
+
+func (r *recordingRepository) record(op string, e *testProxyEntity) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if r.failures > 0 {
+		r.failures--
+		return errors.New("remote down")
+	}
+	r.writes = append(r.writes, op+":"+e.Id)
+	return nil
+}
+
+func (r *recordingRepository) recorded() []string {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	return append([]string{}, r.writes...)
+}
+
+func (r *recordingRepository) Create(ctx context.Context, e *testProxyEntity) (*testProxyEntity, error) {
+	if err := r.record("create", e); err != nil {
+		return e, err
+	}
+	return r.Repository.Create(ctx, e)
+}
+
+func (r *recordingRepository) Update(ctx context.Context, e *testProxyEntity) error {
+	if err := r.record("update", e); err != nil {
+		return err
+	}
+	return r.Repository.Update(ctx, e)
+}
+
+func (r *recordingRepository) Delete(ctx context.Context, e *testProxyEntity) error {
+	if err := r.record("delete", e); err != nil {
+		return err
+	}
+	return r.Repository.Delete(ctx, e)
+}
+
+func TestProxyStore_WriteBehind(t *testing.T) {
+	ctx := context.TODO()
+	remote := newRecordingRepository([]*testProxyEntity{{Id: "1", Attr1: "one"}, {Id: "2", Attr1: "two"}})
+	s := store.NewProxyStore(store.WithWriteBehind[*testProxyEntity](inmemory.UuidIdStrategy[*testProxyEntity]{}, time.Hour, 10, 0))
+	if err := s.Load(ctx, remote); err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	defer s.Close(ctx)
+
+	var added []string
+	_, _ = s.On(store.Added, func(ctx context.Context, e *testProxyEntity) error {
+		added = append(added, e.Attr1)
+		return nil
+	})
+
+	created, err := s.Create(ctx, &testProxyEntity{Attr1: "three"})
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	created.Attr1 = "three updated"
+	_ = s.Update(ctx, created)
+	temporary, _ := s.Create(ctx, &testProxyEntity{Attr1: "temporary"})
+	_ = s.Delete(ctx, temporary)
+	_ = s.Update(ctx, &testProxyEntity{Id: "1", Attr1: "one updated"})
+	_ = s.Update(ctx, &testProxyEntity{Id: "1", Attr1: "one updated twice"})
+	_ = s.Delete(ctx, &testProxyEntity{Id: "2"})
+
+	if got := remote.recorded(); len(got) != 0 {
+		t.Errorf("remote writes before flush = %v, want none", got)
+	}
+	if !reflect.DeepEqual(added, []string{"three", "temporary"}) {
+		t.Errorf("Added events = %v", added)
+	}
+	if _, err = s.Read(ctx, "2"); !errors.Is(err, entity.ErrEntityNotFound) {
+		t.Errorf("Read() deleted entity error = %v, want %v", err, entity.ErrEntityNotFound)
+	}
+
+	stats := s.WriteStats()
+	if stats.Pending != 3 || stats.Coalesced != 3 {
+		t.Errorf("WriteStats() = %+v, want 3 pending and 3 coalesced", stats)
+	}
+
+	if err = s.Flush(ctx); err != nil {
+		t.Fatalf("Flush() error = %v", err)
+	}
+	want := []string{"create:" + created.Id, "update:1", "delete:2"}
+	if got := remote.recorded(); !reflect.DeepEqual(got, want) {
+		t.Errorf("remote writes = %v, want %v", got, want)
+	}
+	if r, _ := remote.Read(ctx, "1"); r.Attr1 != "one updated twice" {
+		t.Errorf("remote entity = %v, want latest update", r.Attr1)
+	}
+	if stats = s.WriteStats(); stats.Pending != 0 || stats.Flushed != 3 {
+		t.Errorf("WriteStats() = %+v", stats)
+	}
+}
+
+func TestProxyStore_WriteBehindRetries(t *testing.T) {
+	ctx := context.TODO()
+	remote := newRecordingRepository(nil)
+	s := store.NewProxyStore(store.WithWriteBehind[*testProxyEntity](inmemory.UuidIdStrategy[*testProxyEntity]{}, time.Millisecond, 10, 3))
+	if err := s.Load(ctx, remote); err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	remote.lock.Lock()
+	remote.failures = 2
+	remote.lock.Unlock()
+	created, _ := s.Create(ctx, &testProxyEntity{Attr1: "retried"})
+
+	deadline := time.Now().Add(time.Second)
+	for s.WriteStats().Pending > 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if err := s.Close(ctx); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	if got := remote.recorded(); !reflect.DeepEqual(got, []string{"create:" + created.Id}) {
+		t.Errorf("remote writes = %v", got)
+	}
+	if stats := s.WriteStats(); stats.Retried != 2 || stats.Dropped != 0 {
+		t.Errorf("WriteStats() = %+v, want 2 retries", stats)
+	}
+}
+
+// objectIDRepository is a remote accepting only ObjectID ids, as the mongo repositories do.
+type objectIDRepository struct {
+	*inmemory.Repository[*testProxyEntity]
+}
+
+func (r *objectIDRepository) Create(ctx context.Context, e *testProxyEntity) (*testProxyEntity, error) {
+	if mongo.TryObjectID(e.GetID()) == nil {
+		return e, errors.New("invalid ObjectID " + e.Id)
+	}
+	return r.Repository.Create(ctx, e)
+}
+
+func TestProxyStore_WriteBehindRemoteIDs(t *testing.T) {
+	ctx := context.TODO()
+	remote := &objectIDRepository{Repository: inmemory.NewRepository([]*testProxyEntity{}, inmemory.WithIsolation[*testProxyEntity]())}
+
+	if err := store.NewProxyStore(store.WithWriteBehind[*testProxyEntity](nil, time.Hour, 10, 0)).Load(ctx, remote); err == nil {
+		t.Errorf("Load() without id strategy expected error")
+	}
+
+	s := store.NewProxyStore(store.WithWriteBehind[*testProxyEntity](mongo.ObjectIDStrategy[*testProxyEntity]{}, time.Hour, 10, 0))
+	if err := s.Load(ctx, remote); err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	created, err := s.Create(ctx, &testProxyEntity{Attr1: "one"})
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	if err = s.Flush(ctx); err != nil {
+		t.Fatalf("Flush() error = %v", err)
+	}
+
+	if stats := s.WriteStats(); stats.Flushed != 1 || stats.Dropped != 0 {
+		t.Errorf("WriteStats() = %+v, want 1 flushed", stats)
+	}
+	if e, err := remote.Read(ctx, created.GetID()); err != nil || e.Attr1 != "one" {
+		t.Errorf("remote Read() = %v, error = %v", e, err)
+	}
+}
+
+func TestProxyStore_WriteBehindSnapshots(t *testing.T) {
+	ctx := context.TODO()
+	remote := newRecordingRepository([]*testProxyEntity{{Id: "1", Attr1: "one"}})
+	s := store.NewProxyStore(store.WithWriteBehind[*testProxyEntity](inmemory.UuidIdStrategy[*testProxyEntity]{}, time.Millisecond, 10, 0))
+	s.OnHydrate(func(ctx context.Context, e *testProxyEntity) (*testProxyEntity, error) {
+		e.SomeNiceField = "hydrated"
+		return e, nil
+	})
+	if err := s.Load(ctx, remote); err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	defer s.Close(ctx)
+
+	// changes made after the writes return, while the queue may be flushing, are not written remotely
+	created, err := s.Create(ctx, &testProxyEntity{Attr1: "a"})
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	updated := &testProxyEntity{Id: "1", Attr1: "uno"}
+	if err = s.Update(ctx, updated); err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+	created.Attr1, updated.Attr1 = "mutated", "mutated"
+	if err = s.Close(ctx); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	for id, want := range map[string]string{created.Id: "a", "1": "uno"} {
+		e, err := remote.Repository.Read(ctx, entity.ID(id))
+		if err != nil || e.Attr1 != want {
+			t.Errorf("remote entity %s = %+v, error = %v, want %s", id, e, err, want)
+		}
+	}
+	if e, _ := remote.Repository.Read(ctx, created.GetID()); e.SomeNiceField != "" {
+		t.Errorf("remote entity %s hydrated = %s, want unhydrated", created.Id, e.SomeNiceField)
+	}
+}
@@ -0,0 +1,36 @@
+package webhook
+
+import (
+	"time"
+
+	"github.com/davfer/crudo/entity"
+)
+
+// DeadLetter is a delivery that failed permanently, Payload is the posted Message.
+type DeadLetter struct {
+	Id       string    `json:"id" bson:"_id,omitempty"`
+	URL      string    `json:"url" bson:"url"`
+	Topic    string    `json:"topic" bson:"topic"`
+	Payload  []byte    `json:"payload" bson:"payload"`
+	Attempts int       `json:"attempts" bson:"attempts"`
+	Error    string    `json:"error" bson:"error"`
+	FailedAt time.Time `json:"failed_at" bson:"failed_at"`
+}
+
+func (d *DeadLetter) GetID() entity.ID {
+	return entity.ID(d.Id)
+}
+
+func (d *DeadLetter) SetID(id entity.ID) error {
+	d.Id = id.String()
+	return nil
+}
+
+func (d *DeadLetter) GetResourceID() (string, error) {
+	return d.Id, nil
+}
+
+func (d *DeadLetter) SetResourceID(s string) error {
+	d.Id = s
+	return nil
+}
@@ -0,0 +1,290 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/davfer/archit/patterns/opts"
+	"github.com/go-logr/logr"
+	"github.com/google/uuid"
+
+	"github.com/davfer/crudo"
+	"github.com/davfer/crudo/notifier"
+)
+
+const (
+	HeaderID        = "X-Webhook-ID"
+	HeaderTopic     = "X-Webhook-Topic"
+	HeaderSignature = "X-Webhook-Signature"
+)
+
+// Endpoint receives the events of the topics matching any of its Topics, every topic when empty. Deliveries are signed
+// with Secret when set.
+type Endpoint struct {
+	URL    string
+	Secret string
+	Topics []string
+}
+
+func (e Endpoint) accepts(topic string) bool {
+	if len(e.Topics) == 0 {
+		return true
+	}
+	for _, pattern := range e.Topics {
+		if notifier.MatchTopic(pattern, topic) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Message is the JSON body posted to the endpoints, ID is the same in every attempt so receivers can deduplicate.
+type Message[K any] struct {
+	ID        string    `json:"id"`
+	Topic     string    `json:"topic"`
+	Timestamp time.Time `json:"timestamp"`
+	Event     K         `json:"event"`
+}
+
+// StatusError is the unexpected response of an endpoint.
+type StatusError struct {
+	URL        string
+	StatusCode int
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("endpoint %s responded %d", e.URL, e.StatusCode)
+}
+
+// Observer posts the events to its endpoints, retrying failed deliveries with an exponential backoff. Deliveries still
+// failing, or rejected with a client error, are stored in the dead letter repository when there is one. Events handled
+// as an observer are queued, so the retries never hold up the change being observed.
+type Observer[K any] struct {
+	Endpoints   []Endpoint
+	Retries     int           // Retries of a failed delivery
+	Backoff     time.Duration // Backoff before the first retry, doubled on every retry up to MaxBackoff
+	MaxBackoff  time.Duration
+	Workers     int // Workers delivering the queued events of each topic
+	QueueSize   int // QueueSize of the events of a topic waiting for a worker, a full queue blocks Handle
+	client      *http.Client
+	deadLetters crudo.Repository[*DeadLetter]
+	logger      logr.Logger
+	queue       *notifier.Dispatcher[K]
+}
+
+func NewObserver[K any](endpoints []Endpoint, o ...opts.Opt[Observer[K]]) *Observer[K] {
+	w := opts.New[Observer[K]](o...)
+
+	w.Endpoints = endpoints
+	if w.Backoff <= 0 {
+		w.Backoff = time.Second
+	}
+	if w.MaxBackoff <= 0 {
+		w.MaxBackoff = time.Minute
+	}
+	if w.client == nil {
+		w.client = &http.Client{Timeout: 10 * time.Second}
+	}
+	if w.logger.GetSink() == nil {
+		w.logger = logr.Discard()
+	}
+	w.queue = notifier.NewDispatcher[K](notifier.PublisherFunc[K](w.Notify),
+		notifier.WithWorkers[K](w.Workers),
+		notifier.WithQueueSize[K](w.QueueSize),
+		notifier.WithDispatcherLogger[K](w.logger),
+	)
+
+	return &w
+}
+
+func WithRetry[K any](retries int, backoff, maxBackoff time.Duration) opts.Opt[Observer[K]] {
+	return func(w Observer[K]) Observer[K] {
+		w.Retries = retries
+		w.Backoff = backoff
+		w.MaxBackoff = maxBackoff
+		return w
+	}
+}
+
+// WithQueue delivers the handled events of each topic from workers, each one with its own queue of up to
+// queueSize/workers events. Events delivered by different workers may arrive out of order.
+func WithQueue[K any](workers, queueSize int) opts.Opt[Observer[K]] {
+	return func(w Observer[K]) Observer[K] {
+		w.Workers = workers
+		w.QueueSize = queueSize
+		return w
+	}
+}
+
+func WithClient[K any](client *http.Client) opts.Opt[Observer[K]] {
+	return func(w Observer[K]) Observer[K] {
+		w.client = client
+		return w
+	}
+}
+
+// WithDeadLetters stores the permanently failed deliveries in repo, for inspection or redelivery.
+func WithDeadLetters[K any](repo crudo.Repository[*DeadLetter]) opts.Opt[Observer[K]] {
+	return func(w Observer[K]) Observer[K] {
+		w.deadLetters = repo
+		return w
+	}
+}
+
+func WithLogger[K any](logger logr.Logger) opts.Opt[Observer[K]] {
+	return func(w Observer[K]) Observer[K] {
+		w.logger = logger
+		return w
+	}
+}
+
+// Handle queues the event to be posted under the topic being handled, see notifier.TopicFromContext. Failed deliveries
+// that could not be dead lettered are logged.
+func (w *Observer[K]) Handle(ctx context.Context, event K) error {
+	topic, _ := notifier.TopicFromContext(ctx)
+	// the deliveries outlive the change being handled
+	return w.queue.Notify(context.WithoutCancel(ctx), topic, event)
+}
+
+// Close stops queuing events and waits for the queued ones to be delivered until ctx is done.
+func (w *Observer[K]) Close(ctx context.Context) error {
+	return w.queue.Close(ctx)
+}
+
+// Notify posts the event to the endpoints of the topic at once, waiting for the retries, making the observer a
+// notifier.Publisher, e.g. of an outbox.Relay. It fails with the deliveries that could not be dead lettered.
+func (w *Observer[K]) Notify(ctx context.Context, topic string, event K) error {
+	msg := Message[K]{ID: uuid.NewString(), Topic: topic, Timestamp: time.Now(), Event: event}
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return fmt.Errorf("could not encode event: %w", err)
+	}
+
+	var wg sync.WaitGroup
+	errs := make([]error, len(w.Endpoints))
+	for i, endpoint := range w.Endpoints {
+		if !endpoint.accepts(topic) {
+			continue
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = w.deliver(ctx, endpoint, msg.ID, topic, body)
+		}()
+	}
+	wg.Wait()
+
+	return errors.Join(errs...)
+}
+
+func (w *Observer[K]) deliver(ctx context.Context, endpoint Endpoint, id, topic string, body []byte) error {
+	backoff := w.Backoff
+	for attempt := 0; ; attempt++ {
+		err := w.post(ctx, endpoint, id, topic, body)
+		if err == nil {
+			return nil
+		}
+		if attempt >= w.Retries || !retryable(err) {
+			w.logger.Error(err, "error delivering webhook", "url", endpoint.URL, "topic", topic, "attempts", attempt+1)
+			return w.deadLetter(ctx, endpoint, topic, body, attempt+1, err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return w.deadLetter(ctx, endpoint, topic, body, attempt+1, errors.Join(err, ctx.Err()))
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, w.MaxBackoff)
+	}
+}
+
+func (w *Observer[K]) post(ctx context.Context, endpoint Endpoint, id, topic string, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint.URL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("could not create request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderID, id)
+	req.Header.Set(HeaderTopic, topic)
+	if endpoint.Secret != "" {
+		req.Header.Set(HeaderSignature, Sign(endpoint.Secret, body))
+	}
+
+	res, err := w.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("could not post to %s: %w", endpoint.URL, err)
+	}
+	defer res.Body.Close()
+	_, _ = io.Copy(io.Discard, res.Body)
+
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		return &StatusError{URL: endpoint.URL, StatusCode: res.StatusCode}
+	}
+
+	return nil
+}
+
+func (w *Observer[K]) deadLetter(ctx context.Context, endpoint Endpoint, topic string, body []byte, attempts int, cause error) error {
+	if w.deadLetters == nil {
+		return cause
+	}
+
+	_, err := w.deadLetters.Create(context.WithoutCancel(ctx), &DeadLetter{
+		URL:      endpoint.URL,
+		Topic:    topic,
+		Payload:  body,
+		Attempts: attempts,
+		Error:    cause.Error(),
+		FailedAt: time.Now(),
+	})
+	if err != nil {
+		return errors.Join(cause, fmt.Errorf("could not store dead letter: %w", err))
+	}
+
+	return nil
+}
+
+// retryable tells whether a failed delivery may succeed later: transport errors, server errors and throttling.
+func retryable(err error) bool {
+	var status *StatusError
+	if !errors.As(err, &status) {
+		return true
+	}
+
+	return status.StatusCode >= 500 || status.StatusCode == http.StatusTooManyRequests || status.StatusCode == http.StatusRequestTimeout
+}
+
+// Sign returns the signature of body sent in HeaderSignature, the hex HMAC-SHA256 of the body keyed by secret.
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify tells whether signature is the one of body, for receivers of the webhooks.
+func Verify(secret string, body []byte, signature string) bool {
+	got, err := hex.DecodeString(strings.TrimPrefix(signature, "sha256="))
+	if err != nil {
+		return false
+	}
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+
+	return hmac.Equal(got, mac.Sum(nil))
+}
@@ -0,0 +1,162 @@
+package webhook_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/davfer/crudo/inmemory"
+	"github.com/davfer/crudo/notifier"
+	"github.com/davfer/crudo/webhook"
+)
+
+type testEvent struct {
+	Name string
+}
+
+// endpoint records the deliveries it receives, answering them with the given statuses in turn.
+type endpoint struct {
+	lock     sync.Mutex
+	statuses []int
+	received []webhook.Message[testEvent]
+	verified []bool
+}
+
+func (e *endpoint) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	var msg webhook.Message[testEvent]
+	_ = json.Unmarshal(body, &msg)
+
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	status := http.StatusOK
+	if len(e.statuses) > 0 {
+		status, e.statuses = e.statuses[0], e.statuses[1:]
+	}
+	e.received = append(e.received, msg)
+	e.verified = append(e.verified, webhook.Verify("secret", body, r.Header.Get(webhook.HeaderSignature)) && r.Header.Get(webhook.HeaderID) == msg.ID)
+	w.WriteHeader(status)
+}
+
+func TestObserver(t *testing.T) {
+	ctx := context.TODO()
+	users, orders := &endpoint{statuses: []int{500, 503}}, &endpoint{}
+	usersServer, ordersServer := httptest.NewServer(users), httptest.NewServer(orders)
+	defer usersServer.Close()
+	defer ordersServer.Close()
+
+	w := webhook.NewObserver[testEvent]([]webhook.Endpoint{
+		{URL: usersServer.URL, Secret: "secret", Topics: []string{"users.*"}},
+		{URL: ordersServer.URL, Secret: "secret", Topics: []string{"orders.*"}},
+	}, webhook.WithRetry[testEvent](2, time.Millisecond, 5*time.Millisecond))
+
+	n := notifier.NewTopicNotifier[testEvent]([]string{"users.added", "orders.added"})
+	_, _ = n.Attach("*", w)
+	if err := n.Notify(ctx, "users.added", testEvent{Name: "alice"}); err != nil {
+		t.Fatalf("Notify() error = %v", err)
+	}
+	if err := w.Close(ctx); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	// retried until accepted, the delivery id and signature do not change
+	if len(users.received) != 3 || users.received[0].ID != users.received[2].ID {
+		t.Errorf("users deliveries = %+v, want 3 attempts of the same message", users.received)
+	}
+	if !reflect.DeepEqual(users.verified, []bool{true, true, true}) {
+		t.Errorf("users signatures verified = %v", users.verified)
+	}
+	if users.received[0].Topic != "users.added" || users.received[0].Event.Name != "alice" {
+		t.Errorf("users delivery = %+v", users.received[0])
+	}
+	if len(orders.received) != 0 {
+		t.Errorf("orders deliveries = %+v, want none", orders.received)
+	}
+}
+
+func TestObserver_Queued(t *testing.T) {
+	ctx := context.TODO()
+	e := &endpoint{statuses: []int{500, 500}}
+	server := httptest.NewServer(e)
+	defer server.Close()
+
+	w := webhook.NewObserver[testEvent]([]webhook.Endpoint{{URL: server.URL}},
+		webhook.WithRetry[testEvent](2, 100*time.Millisecond, 100*time.Millisecond), webhook.WithQueue[testEvent](2, 10))
+	n := notifier.NewTopicNotifier[testEvent]([]string{"users.added"})
+	_, _ = n.Attach("*", w)
+
+	// the change being observed does not wait for the retries
+	start := time.Now()
+	if err := n.Notify(ctx, "users.added", testEvent{Name: "alice"}); err != nil {
+		t.Fatalf("Notify() error = %v", err)
+	}
+	if elapsed := time.Since(start); elapsed >= 100*time.Millisecond {
+		t.Errorf("Notify() took %v, want it queued", elapsed)
+	}
+
+	closeCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	if err := w.Close(closeCtx); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	if len(e.received) != 3 {
+		t.Errorf("deliveries = %d, want 3", len(e.received))
+	}
+	if err := w.Handle(ctx, testEvent{Name: "bob"}); !errors.Is(err, notifier.ErrDispatcherClosed) {
+		t.Errorf("Handle() after Close error = %v, want %v", err, notifier.ErrDispatcherClosed)
+	}
+}
+
+func TestObserver_DeadLetters(t *testing.T) {
+	ctx := context.TODO()
+	tests := []struct {
+		name         string
+		statuses     []int
+		wantAttempts int
+	}{
+		{"retries exhausted", []int{500, 500, 500}, 3},
+		{"rejected", []int{400}, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := &endpoint{statuses: tt.statuses}
+			server := httptest.NewServer(e)
+			defer server.Close()
+
+			deadLetters := inmemory.NewRepository([]*webhook.DeadLetter{})
+			w := webhook.NewObserver[testEvent]([]webhook.Endpoint{{URL: server.URL}},
+				webhook.WithRetry[testEvent](2, time.Millisecond, time.Millisecond))
+			if err := w.Notify(ctx, "users.added", testEvent{Name: "alice"}); err == nil {
+				t.Errorf("Notify() without dead letters expected error")
+			}
+
+			e.statuses = tt.statuses
+			e.received = nil
+			w = webhook.NewObserver[testEvent]([]webhook.Endpoint{{URL: server.URL}},
+				webhook.WithRetry[testEvent](2, time.Millisecond, time.Millisecond), webhook.WithDeadLetters[testEvent](deadLetters))
+			if err := w.Notify(ctx, "users.added", testEvent{Name: "alice"}); err != nil {
+				t.Fatalf("Notify() error = %v", err)
+			}
+
+			letters, _ := deadLetters.ReadAll(ctx)
+			if len(letters) != 1 || letters[0].Attempts != tt.wantAttempts || letters[0].URL != server.URL {
+				t.Fatalf("dead letters = %+v, want one after %d attempts", letters, tt.wantAttempts)
+			}
+			var msg webhook.Message[testEvent]
+			if err := json.Unmarshal(letters[0].Payload, &msg); err != nil || msg.Event.Name != "alice" {
+				t.Errorf("dead letter payload = %s", letters[0].Payload)
+			}
+			if len(e.received) != tt.wantAttempts {
+				t.Errorf("deliveries = %d, want %d", len(e.received), tt.wantAttempts)
+			}
+		})
+	}
+}